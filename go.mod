@@ -2,4 +2,9 @@ module github.com/hashcracky/brainstorm
 
 go 1.26.1
 
-require golang.org/x/text v0.31.0
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/ulikunitz/xz v0.5.15
+	golang.org/x/crypto v0.44.0
+	golang.org/x/text v0.31.0
+)
@@ -2,11 +2,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/hashcracky/brainstorm/pkg/mutate"
 	"github.com/hashcracky/brainstorm/pkg/structs"
@@ -15,6 +21,11 @@ import (
 // version is the current version of the brainstorm application.
 var version = "1.0.0"
 
+// exitForced is the exit status after a second signal quits without
+// flushing. A run stopped cleanly by its first signal exits with 128 plus
+// the signal number, as shells report it.
+const exitForced = 2
+
 // parseRangeFlag parses a range in the form "start-end" and returns
 // the corresponding integer bounds.
 //
@@ -58,6 +69,119 @@ func parseRangeFlag(value string, defaultStart int, defaultEnd int) (int, int, e
 	return start, end, nil
 }
 
+// stringListFlag collects a repeatable string flag. Each occurrence may also
+// hold a comma-separated list of values.
+type stringListFlag []string
+
+// String returns the collected values joined by commas.
+//
+// Returns:
+// string - Comma-separated flag values.
+func (s *stringListFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set appends one or more comma-separated values to the list.
+//
+// Args:
+// value: string - Raw flag value.
+//
+// Returns:
+// error - Always nil.
+func (s *stringListFlag) Set(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			*s = append(*s, part)
+		}
+	}
+
+	return nil
+}
+
+// parseSeparators converts -sep values into joiner strings. The keywords
+// none, space, and comma stand for the empty string, a space, and a comma,
+// which cannot otherwise be written in a comma-separated list.
+//
+// Args:
+// values: []string - Raw -sep values.
+//
+// Returns:
+// []string - Joiner strings, or nil when no values were given.
+func parseSeparators(values []string) []string {
+	var separators []string
+
+	for _, value := range values {
+		switch value {
+		case "none":
+			separators = append(separators, "")
+		case "space":
+			separators = append(separators, " ")
+		case "comma":
+			separators = append(separators, ",")
+		default:
+			separators = append(separators, value)
+		}
+	}
+
+	return separators
+}
+
+// parseThreads parses a -threads value: a positive worker count, or auto.
+//
+// Args:
+// value: string - Raw -threads value.
+//
+// Returns:
+// int - Worker count, or 0 for auto.
+// error - Error if the value is malformed.
+func parseThreads(value string) (int, error) {
+	if value == "auto" {
+		return 0, nil
+	}
+
+	threads, err := strconv.Atoi(value)
+	if err != nil || threads < 1 {
+		return 0, fmt.Errorf("expected a positive worker count or auto, got %q", value)
+	}
+
+	return threads, nil
+}
+
+// parseByteSize parses a size in bytes with an optional K, M, or G suffix
+// for KiB, MiB, or GiB (for example, 512K or 4M).
+//
+// Args:
+// value: string - Raw size string.
+//
+// Returns:
+// int - Size in bytes.
+// error - Error if the value is malformed or not positive.
+func parseByteSize(value string) (int, error) {
+	number := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(value), "B"), "I")
+	multiplier := 1
+
+	switch {
+	case strings.HasSuffix(number, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(number, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(number, "G"):
+		multiplier = 1 << 30
+	}
+
+	if multiplier > 1 {
+		number = number[:len(number)-1]
+	}
+
+	size, err := strconv.Atoi(number)
+	if err != nil || size < 1 || size > math.MaxInt32/multiplier {
+		return 0, fmt.Errorf("expected a positive size such as 65536, 512K, or 4M, got %q", value)
+	}
+
+	return size * multiplier, nil
+}
+
 // parseFlags parses command-line flags and returns a Config.
 //
 // The supported flags are:
@@ -65,6 +189,53 @@ func parseRangeFlag(value string, defaultStart int, defaultEnd int) (int, int, e
 //	-w: string - N-gram word length range, in the form start-end (for example, 1-5).
 //	-l: string - Final output length range, in the form min-max (for example, 4-32).
 //	-unicode: bool - Relax Latin-centric heuristics to include non-Latin multi-byte letter sequences.
+//	-r, -recursive: bool - Descend into subdirectories of directory inputs.
+//	-include-glob: string - File name pattern a directory entry must match (repeatable).
+//	-exclude-glob: string - File name pattern that skips a directory entry (repeatable).
+//	-mode: string - Unit of input processed at a time: line or sentence.
+//	-dedup: string - Deduplicate output with the exact, bloom, or disk strategy.
+//	-dedup-fp: float - Target false-positive rate of the bloom strategy.
+//	-dedup-capacity: int - Expected number of distinct candidates for the bloom strategy.
+//	-sort-mem: int - Memory cap in MiB before disk-backed stages spill to temporary files.
+//	-tmpdir: string - Directory for temporary spill files.
+//	-count: bool - Tally candidates and write them by descending frequency.
+//	-count-format: string - Count output format, tsv or plain.
+//	-top: int - Write only the N most frequent candidates.
+//	-min-count: int - Write only candidates produced at least this many times.
+//	-ordered: bool - Write results in input order.
+//	-order-window: int - Maximum number of input lines in flight while reordering.
+//	-styles: string - Case styles applied to each n-gram (repeatable, comma-separated).
+//	-sep: string - Separators joining the words of each n-gram (repeatable, comma-separated).
+//	-leet: string - Character substitution mode: all, single, or first.
+//	-leet-table: string - Substitution table file in hashcat x=y format.
+//	-leet-positions: int - Number of leading substitutable positions for -leet first.
+//	-leet-max: int - Maximum substitution variants per candidate.
+//	-prefix: string - Affix spec prepended to candidates (repeatable).
+//	-suffix: string - Affix spec appended to candidates (repeatable).
+//	-rules-out: string - Write base candidates only, plus a hashcat rule file reproducing the variants.
+//	-hashes: string - Only write candidates matching a hash in this file, as hash:plaintext.
+//	-algo: string - Hash algorithm for -hashes: md5, sha1, sha256, sha512, or ntlm.
+//	-potfile: string - Skip hashes and plaintexts already present in this potfile.
+//	-heuristics: string - Word-likeness threshold preset: off, loose, default, or strict.
+//	-heuristics-file: string - File of key=value threshold overrides applied on top of the preset.
+//	-heuristic: string - Threshold override in the form key=value (repeatable, comma-separated).
+//	-lang: string - Language profile for the word heuristics, or auto to pick one per line.
+//	-model: string - Character model built with 'brainstorm train'; lines with a word scoring below -min-score are rejected.
+//	-min-score: float - Minimum mean log10 probability per character of each word under -model.
+//	-rejects: string - Write lines and candidates dropped by the heuristics, with reason codes, to this file.
+//	-explain: string - Print the decision trace for a single input and exit.
+//	-stats-json: string - Write the run statistics to this file as JSON.
+//	-progress: bool - Periodically print progress to stderr.
+//	-checkpoint: string - Periodically record the processed input position in this file.
+//	-threads: string - Number of worker goroutines, or auto to size from the CPUs and cgroup CPU quota.
+//	-queue: int - Number of input lines the worker queue holds, rounded up to whole batches and at least one batch per worker.
+//	-bufsize: string - Size of the input and output buffers, with an optional K, M, or G suffix.
+//	-max-line: string - Maximum line size before a line is split, with an optional K, M, or G suffix.
+//	-resume: bool - Continue from the position recorded by -checkpoint, appending to the existing output.
+//	-exclude: string - Remove candidates present in this wordlist (repeatable, "sorted:" or "bloom:" prefix selects the backend).
+//
+// Remaining positional arguments are input files, directories, or glob
+// patterns. Standard input is read when none are given.
 //
 // Returns:
 // *structs.Config - Pointer to the populated configuration struct.
@@ -87,10 +258,303 @@ func parseFlags() *structs.Config {
 		"Include non-Latin multi-byte letter sequences by relaxing Latin vowel heuristics.",
 	)
 
+	var recursive bool
+
+	flag.BoolVar(
+		&recursive,
+		"r",
+		false,
+		"Recursively read files in subdirectories of directory inputs.",
+	)
+
+	flag.BoolVar(
+		&recursive,
+		"recursive",
+		false,
+		"Recursively read files in subdirectories of directory inputs (same as -r).",
+	)
+
+	var includeGlobs stringListFlag
+
+	flag.Var(
+		&includeGlobs,
+		"include-glob",
+		"Only read directory entries whose file name matches this pattern (repeatable, comma-separated).",
+	)
+
+	var excludeGlobs stringListFlag
+
+	flag.Var(
+		&excludeGlobs,
+		"exclude-glob",
+		"Skip directory entries whose file name matches this pattern (repeatable, comma-separated).",
+	)
+
+	mode := flag.String(
+		"mode",
+		mutate.ModeLine,
+		"Unit of input to process: line, or sentence to join wrapped lines and split the text into sentences at punctuation and blank lines.",
+	)
+
+	dedup := flag.String(
+		"dedup",
+		"",
+		"Deduplicate output candidates: exact (in-memory set), bloom (bounded memory, probabilistic), or disk (external sort, sorted output).",
+	)
+
+	dedupFalsePositive := flag.Float64(
+		"dedup-fp",
+		0.001,
+		"Target false-positive rate for -dedup bloom.",
+	)
+
+	dedupCapacity := flag.Int(
+		"dedup-capacity",
+		100_000_000,
+		"Expected number of distinct candidates for -dedup bloom; sets the filter size.",
+	)
+
+	sortMemoryMB := flag.Int(
+		"sort-mem",
+		512,
+		"Memory cap in MiB before disk-backed stages spill to temporary files.",
+	)
+
+	tempDir := flag.String(
+		"tmpdir",
+		"",
+		"Directory for temporary spill files (defaults to the system temporary directory).",
+	)
+
+	count := flag.Bool(
+		"count",
+		false,
+		"Tally how often each candidate is produced and write candidates by descending frequency.",
+	)
+
+	countFormat := flag.String(
+		"count-format",
+		mutate.CountFormatTSV,
+		"Output format for -count: tsv (count<TAB>candidate) or plain (candidate only).",
+	)
+
+	countTop := flag.Int(
+		"top",
+		0,
+		"With -count, write only the N most frequent candidates (0 writes all).",
+	)
+
+	countMin := flag.Int64(
+		"min-count",
+		1,
+		"With -count, write only candidates produced at least this many times.",
+	)
+
+	ordered := flag.Bool(
+		"ordered",
+		false,
+		"Write results in input order so that repeated runs produce identical output.",
+	)
+
+	orderWindow := flag.Int(
+		"order-window",
+		8192,
+		"Maximum number of input lines in flight while reordering for -ordered.",
+	)
+
+	var caseStyles stringListFlag
+
+	flag.Var(
+		&caseStyles,
+		"styles",
+		"Case styles for each n-gram: lower, upper, title, camel, sentence, original (repeatable, comma-separated; default title).",
+	)
+
+	var separators stringListFlag
+
+	flag.Var(
+		&separators,
+		"sep",
+		"Separators joining n-gram words; use none, space, and comma for \"\", \" \", and \",\" (repeatable, comma-separated; default none).",
+	)
+
+	leetMode := flag.String(
+		"leet",
+		"",
+		"Add character substitution variants (a->@, e->3, ...): all (every combination), single (one position at a time), or first (combinations of the first -leet-positions positions).",
+	)
+
+	leetTable := flag.String(
+		"leet-table",
+		"",
+		"Substitution table file with one x=y pair per line (hashcat table format); the built-in table is used when empty.",
+	)
+
+	leetPositions := flag.Int(
+		"leet-positions",
+		2,
+		"Number of leading substitutable positions used by -leet first.",
+	)
+
+	leetMaxVariants := flag.Int(
+		"leet-max",
+		64,
+		"Maximum number of substitution variants per candidate (0 is unlimited).",
+	)
+
+	var prefixSpecs stringListFlag
+
+	flag.Var(
+		&prefixSpecs,
+		"prefix",
+		"Prepend affixes to candidates: digits:N-M, num:A-B, years:A-B, specials, file:PATH, or lit:TEXT, joined with + to combine (repeatable, comma-separated).",
+	)
+
+	var suffixSpecs stringListFlag
+
+	flag.Var(
+		&suffixSpecs,
+		"suffix",
+		"Append affixes to candidates, using the same specs as -prefix (repeatable, comma-separated).",
+	)
+
+	rulesOut := flag.String(
+		"rules-out",
+		"",
+		"Write only base candidates and save hashcat rules reproducing the requested case, separator, substitution, and affix variants to this file.",
+	)
+
+	hashFile := flag.String(
+		"hashes",
+		"",
+		"Only write candidates whose hash appears in this file (one hex hash per line), in potfile hash:plaintext format.",
+	)
+
+	hashAlgo := flag.String(
+		"algo",
+		mutate.HashMD5,
+		"Hash algorithm for -hashes: md5, sha1, sha256, sha512, or ntlm.",
+	)
+
+	potFile := flag.String(
+		"potfile",
+		"",
+		"Skip hashes and plaintexts already present in this potfile when using -hashes.",
+	)
+
+	var excludeLists stringListFlag
+
+	flag.Var(
+		&excludeLists,
+		"exclude",
+		"Remove candidates already present in this wordlist; prefix with sorted: for a byte-sorted file searched in place, or bloom: for a filter built with 'brainstorm bloom' (repeatable, comma-separated).",
+	)
+
+	heuristicsPreset := flag.String(
+		"heuristics",
+		mutate.HeuristicsDefault,
+		"Word-likeness threshold preset: off (keep every line with a letter), loose, default, or strict.",
+	)
+
+	heuristicsFile := flag.String(
+		"heuristics-file",
+		"",
+		"File of key=value threshold overrides, one per line, applied on top of the -heuristics preset.",
+	)
+
+	var heuristicOverrides stringListFlag
+
+	flag.Var(
+		&heuristicOverrides,
+		"heuristic",
+		"Override one threshold, for example max-consonant-run=6; applied after -heuristics-file (repeatable, comma-separated).",
+	)
+
+	language := flag.String(
+		"lang",
+		mutate.LanguageDefault,
+		"Language profile for vowels, digraphs, and uncommon letter pairs in the word heuristics: "+strings.Join(mutate.LanguageNames(), ", ")+", or auto to pick one per line.",
+	)
+
+	modelFile := flag.String(
+		"model",
+		"",
+		"Character model built with 'brainstorm train'; lines with any word scoring below -min-score are rejected, in addition to the -heuristics checks.",
+	)
+
+	minModelScore := flag.Float64(
+		"min-score",
+		mutate.DefaultMinModelScore,
+		"Minimum mean log10 probability per character of every word of a line under -model; higher is stricter.",
+	)
+
+	rejectsOut := flag.String(
+		"rejects",
+		"",
+		"Write every input line and candidate dropped by the word heuristics to this file as reason<TAB>text lines.",
+	)
+
+	explain := flag.String(
+		"explain",
+		"",
+		"Print the full decision trace for this input text, with the reason for every dropped line or candidate, and exit.",
+	)
+
+	statsJSON := flag.String(
+		"stats-json",
+		"",
+		"Write the run statistics (throughput, per-stage counts, rejections, and histograms) to this file as JSON.",
+	)
+
+	progress := flag.Bool(
+		"progress",
+		false,
+		"Print lines, bytes, and candidates processed, throughput, and queue depth to stderr every few seconds, with percent complete and ETA for file inputs.",
+	)
+
+	checkpoint := flag.String(
+		"checkpoint",
+		"",
+		"Record the position of the last processed and flushed input line of each input in this file every few seconds; implies -ordered.",
+	)
+
+	threadsFlag := flag.String(
+		"threads",
+		"auto",
+		"Number of worker goroutines, or auto to use the available CPUs, capped by the container's cgroup CPU quota.",
+	)
+
+	queue := flag.Int(
+		"queue",
+		mutate.DefaultQueueSize,
+		"Number of input lines the worker queue holds, rounded up to whole batches of up to 256 lines and at least one batch per worker.",
+	)
+
+	bufsize := flag.String(
+		"bufsize",
+		"1M",
+		"Size of the input and output buffers in bytes, with an optional K, M, or G suffix.",
+	)
+
+	maxLine := flag.String(
+		"max-line",
+		"64K",
+		"Split input lines longer than this many bytes on sentence or whitespace boundaries, with an optional K, M, or G suffix.",
+	)
+
+	resume := flag.Bool(
+		"resume",
+		false,
+		"Continue from the position recorded in the -checkpoint file; append to the original output with >>.",
+	)
+
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of Brainstorm version (%s):\n\n", version)
-		fmt.Fprintf(os.Stderr, "input | brainstorm [options] > output\n\n")
-		fmt.Fprintf(os.Stderr, "Accepts standard input and writes transformed output to standard output.\n\n")
+		fmt.Fprintf(os.Stderr, "input | brainstorm [options] > output\n")
+		fmt.Fprintf(os.Stderr, "brainstorm [options] [file | directory | glob ...] > output\n")
+		fmt.Fprintf(os.Stderr, "brainstorm bloom [-fp rate] -o filter.bloom wordlist ...\n")
+		fmt.Fprintf(os.Stderr, "brainstorm train [-order n] -o model.bin reference ...\n\n")
+		fmt.Fprintf(os.Stderr, "Reads the given files, directories, and glob patterns, or standard input when none are given, and writes transformed output to standard output. An input named bloom or train is read as a subcommand unless it exists; write ./bloom or brainstorm -- bloom to read it as input.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
@@ -109,23 +573,468 @@ func parseFlags() *structs.Config {
 		os.Exit(1)
 	}
 
+	if *mode != mutate.ModeLine && *mode != mutate.ModeSentence {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -mode value: %q, expected line or sentence\n", *mode)
+		os.Exit(1)
+	}
+
+	switch *dedup {
+	case mutate.DedupOff, mutate.DedupExact, mutate.DedupBloom, mutate.DedupDisk:
+	default:
+		fmt.Fprintf(os.Stderr, "[!] Invalid -dedup value: %q, expected exact, bloom, or disk\n", *dedup)
+		os.Exit(1)
+	}
+
+	if *dedupFalsePositive <= 0 || *dedupFalsePositive >= 1 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -dedup-fp value: %g, expected a rate between 0 and 1\n", *dedupFalsePositive)
+		os.Exit(1)
+	}
+
+	if *dedupCapacity <= 0 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -dedup-capacity value: %d, expected a positive count\n", *dedupCapacity)
+		os.Exit(1)
+	}
+
+	if *sortMemoryMB <= 0 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -sort-mem value: %d, expected a positive size in MiB\n", *sortMemoryMB)
+		os.Exit(1)
+	}
+
+	if *countFormat != mutate.CountFormatTSV && *countFormat != mutate.CountFormatPlain {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -count-format value: %q, expected tsv or plain\n", *countFormat)
+		os.Exit(1)
+	}
+
+	if *countTop < 0 || *countMin < 1 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -top or -min-count value, expected -top >= 0 and -min-count >= 1\n")
+		os.Exit(1)
+	}
+
+	if *count && *dedup != mutate.DedupOff {
+		fmt.Fprintf(os.Stderr, "[!] -count output is already unique and cannot be combined with -dedup\n")
+		os.Exit(1)
+	}
+
+	threads, err := parseThreads(*threadsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -threads value: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *queue < 1 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -queue value: %d, expected a positive line count\n", *queue)
+		os.Exit(1)
+	}
+
+	bufferSize, err := parseByteSize(*bufsize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -bufsize value: %v\n", err)
+		os.Exit(1)
+	}
+
+	maxLineSize, err := parseByteSize(*maxLine)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -max-line value: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *resume && *checkpoint == "" {
+		fmt.Fprintf(os.Stderr, "[!] -resume requires -checkpoint\n")
+		os.Exit(1)
+	}
+
+	if *checkpoint != "" && (*count || *dedup == mutate.DedupDisk) {
+		fmt.Fprintf(os.Stderr, "[!] -checkpoint cannot be combined with -count or -dedup disk, which write output only at the end\n")
+		os.Exit(1)
+	}
+
+	if *checkpoint != "" && (*dedup == mutate.DedupExact || *dedup == mutate.DedupBloom) {
+		fmt.Fprintf(os.Stderr, "[!] -checkpoint cannot be combined with -dedup %s, whose set of seen candidates is not saved and would repeat candidates on -resume\n", *dedup)
+		os.Exit(1)
+	}
+
+	if *orderWindow < 1 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -order-window value: %d, expected a positive line count\n", *orderWindow)
+		os.Exit(1)
+	}
+
+	for _, style := range caseStyles {
+		if !mutate.IsValidCaseStyle(style) {
+			fmt.Fprintf(os.Stderr, "[!] Invalid -styles value: %q, expected lower, upper, title, camel, sentence, or original\n", style)
+			os.Exit(1)
+		}
+	}
+
+	switch *leetMode {
+	case mutate.LeetOff, mutate.LeetAll, mutate.LeetSingle, mutate.LeetFirst:
+	default:
+		fmt.Fprintf(os.Stderr, "[!] Invalid -leet value: %q, expected all, single, or first\n", *leetMode)
+		os.Exit(1)
+	}
+
+	if *leetPositions < 1 || *leetMaxVariants < 0 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -leet-positions or -leet-max value, expected -leet-positions >= 1 and -leet-max >= 0\n")
+		os.Exit(1)
+	}
+
+	var substitutionTable map[rune][]string
+
+	if *leetTable != "" {
+		table, err := mutate.LoadSubstitutionTable(*leetTable)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!] Invalid -leet-table value: %v\n", err)
+			os.Exit(1)
+		}
+
+		substitutionTable = table
+	}
+
+	prefixes, err := mutate.ParseAffixSpecs(prefixSpecs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -prefix value: %v\n", err)
+		os.Exit(1)
+	}
+
+	suffixes, err := mutate.ParseAffixSpecs(suffixSpecs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -suffix value: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !mutate.IsValidHashAlgo(*hashAlgo) {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -algo value: %q, expected md5, sha1, sha256, sha512, or ntlm\n", *hashAlgo)
+		os.Exit(1)
+	}
+
+	if *rulesOut != "" && (*leetMode == mutate.LeetSingle || *leetMode == mutate.LeetFirst) {
+		fmt.Fprintf(os.Stderr, "[!] -rules-out supports -leet all only; -leet %s substitutes positions no rule can select\n", *leetMode)
+		os.Exit(1)
+	}
+
+	if *hashFile != "" && (*rulesOut != "" || *count) {
+		fmt.Fprintf(os.Stderr, "[!] -hashes cannot be combined with -rules-out or -count\n")
+		os.Exit(1)
+	}
+
+	heuristics, err := mutate.HeuristicPreset(*heuristicsPreset)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -heuristics value: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *heuristicsFile != "" {
+		if err := mutate.LoadHeuristicsFile(&heuristics, *heuristicsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "[!] Invalid -heuristics-file value: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	for _, override := range heuristicOverrides {
+		if err := mutate.SetHeuristic(&heuristics, override); err != nil {
+			fmt.Fprintf(os.Stderr, "[!] Invalid -heuristic value: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if !mutate.IsValidLanguage(*language) {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -lang value: %q, expected %s, or auto\n", *language, strings.Join(mutate.LanguageNames(), ", "))
+		os.Exit(1)
+	}
+
+	var model *structs.CharModel
+
+	if *modelFile != "" {
+		model, err = mutate.LoadCharModel(*modelFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "[!] Invalid -model value: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	cfg := &structs.Config{
 		NGramMin:        nStart,
 		NGramMax:        nEnd,
 		OutMinLength:    outStart,
 		OutMaxLength:    outEnd,
 		IncludeNonLatin: *includeNonLatin,
+		InputPaths:      flag.Args(),
+		Recursive:       recursive,
+		IncludeGlobs:    includeGlobs,
+		ExcludeGlobs:    excludeGlobs,
+		Mode:            *mode,
+
+		Dedup:              *dedup,
+		DedupFalsePositive: *dedupFalsePositive,
+		DedupCapacity:      *dedupCapacity,
+		SortMemoryMB:       *sortMemoryMB,
+		TempDir:            *tempDir,
+
+		Count:       *count,
+		CountFormat: *countFormat,
+		CountTop:    *countTop,
+		CountMin:    *countMin,
+
+		Ordered:     *ordered,
+		OrderWindow: *orderWindow,
+
+		CaseStyles: caseStyles,
+		Separators: parseSeparators(separators),
+
+		LeetMode:        *leetMode,
+		LeetTable:       substitutionTable,
+		LeetPositions:   *leetPositions,
+		LeetMaxVariants: *leetMaxVariants,
+
+		Prefixes: prefixes,
+		Suffixes: suffixes,
+
+		RulesOut: *rulesOut,
+
+		HashFile: *hashFile,
+		HashAlgo: *hashAlgo,
+		PotFile:  *potFile,
+
+		ExcludeLists: excludeLists,
+
+		Heuristics: heuristics,
+		Language:   *language,
+
+		Model:         model,
+		MinModelScore: *minModelScore,
+
+		RejectsOut: *rejectsOut,
+		StatsJSON:  *statsJSON,
+		Explain:    *explain,
+
+		Progress: *progress,
+
+		Checkpoint: *checkpoint,
+		Resume:     *resume,
+
+		Threads:    threads,
+		QueueSize:  *queue,
+		BufferSize: bufferSize,
+
+		MaxLineSize: maxLineSize,
 	}
 
 	return cfg
 }
 
+// runBloomCommand implements the "bloom" subcommand, which builds a Bloom
+// filter file from wordlists for use with -exclude bloom:PATH.
+//
+// Args:
+// args: []string - Arguments following the subcommand name.
+func runBloomCommand(args []string) {
+	flags := flag.NewFlagSet("bloom", flag.ExitOnError)
+
+	falsePositive := flags.Float64(
+		"fp",
+		0.001,
+		"Target false-positive rate of the filter.",
+	)
+
+	out := flags.String(
+		"o",
+		"",
+		"Output filter file.",
+	)
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: brainstorm bloom [-fp rate] -o filter.bloom wordlist ...\n\n")
+		fmt.Fprintf(os.Stderr, "Builds a Bloom filter holding every line of the given wordlists, for use with -exclude bloom:filter.bloom.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if *out == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	if *falsePositive <= 0 || *falsePositive >= 1 {
+		fmt.Fprintf(os.Stderr, "[!] Invalid -fp value: %g, expected a rate between 0 and 1\n", *falsePositive)
+		os.Exit(1)
+	}
+
+	total, err := mutate.BuildBloomFile(flags.Args(), *falsePositive, *out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %s.\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "[*] Wrote %d entries to %s.\n", total, *out)
+}
+
+// runTrainCommand implements the "train" subcommand, which builds a
+// character model from reference text for use with -model.
+//
+// Args:
+// args: []string - Arguments following the subcommand name.
+func runTrainCommand(args []string) {
+	flags := flag.NewFlagSet("train", flag.ExitOnError)
+
+	order := flags.Int(
+		"order",
+		mutate.DefaultModelOrder,
+		"Number of characters per n-gram, including the predicted character.",
+	)
+
+	out := flags.String(
+		"o",
+		"",
+		"Output model file.",
+	)
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: brainstorm train [-order n] -o model.bin reference ...\n\n")
+		fmt.Fprintf(os.Stderr, "Builds a character n-gram model from clean reference text, for use with -model model.bin.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+
+	_ = flags.Parse(args)
+
+	if *out == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	model, err := mutate.TrainCharModel(flags.Args(), *order)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %s.\n", err)
+		os.Exit(1)
+	}
+
+	if err := mutate.WriteCharModel(model, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "[!] %s.\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "[*] Wrote %d n-grams of order %d to %s.\n", len(model.Counts), model.Order, *out)
+}
+
+// subcommand returns the subcommand named by the first argument. A
+// subcommand name only counts before any flag, and not when a file or
+// directory of that name exists, which is read as input instead; "--"
+// before the name or a path such as "./bloom" reads it as input too.
+//
+// Args:
+// args: []string - Command-line arguments, without the program name.
+//
+// Returns:
+// string - Subcommand name, or "" to process input.
+func subcommand(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	switch args[0] {
+	case "bloom", "train":
+		if _, err := os.Stat(args[0]); err == nil {
+			fmt.Fprintf(os.Stderr, "[*] Reading %q as input; run the %s subcommand from a directory without it.\n", args[0], args[0])
+			return ""
+		}
+
+		return args[0]
+	}
+
+	return ""
+}
+
 // main is the entry point for the brainstorm application.
 func main() {
+	switch subcommand(os.Args[1:]) {
+	case "bloom":
+		runBloomCommand(os.Args[2:])
+		return
+	case "train":
+		runTrainCommand(os.Args[2:])
+		return
+	}
+
 	cfg := parseFlags()
 
-	if err := mutate.ProcessStream(cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "[!] %s.\n", err)
-		os.Exit(1)
+	ctx, received := handleSignals()
+
+	if err := mutate.ProcessStreamContext(ctx, cfg); err != nil {
+		sig := received()
+		status := exitStatus(err, sig)
+
+		switch {
+		case errors.Is(err, syscall.EPIPE):
+			// The reader of the output went away, as with
+			// "brainstorm | head"; exit without a message.
+		case status != 1:
+			fmt.Fprintf(os.Stderr, "[*] Stopped by %s; output flushed.\n", sig)
+		default:
+			fmt.Fprintf(os.Stderr, "[!] %s.\n", err)
+		}
+
+		os.Exit(status)
+	}
+}
+
+// exitStatus returns the exit status for a failed run. A closed output pipe
+// exits as a process killed by SIGPIPE would, and a run stopped by a signal
+// exits with 128 plus the signal number, as the shell reports a process
+// killed by it. Any other error exits with 1.
+//
+// Args:
+// err: error - Error returned by the run.
+// sig: os.Signal - First signal received, or nil.
+//
+// Returns:
+// int - Process exit status.
+func exitStatus(err error, sig os.Signal) int {
+	if errors.Is(err, syscall.EPIPE) {
+		return 128 + int(syscall.SIGPIPE)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		if sig, ok := sig.(syscall.Signal); ok {
+			return 128 + int(sig)
+		}
+	}
+
+	return 1
+}
+
+// handleSignals cancels the returned context on the first SIGINT or SIGTERM,
+// so that the run stops reading, finishes the lines already read, and
+// flushes its output. A second signal exits immediately. SIGPIPE is ignored,
+// so a write to a closed pipe fails with EPIPE and the run can stop cleanly
+// instead of being killed.
+//
+// Returns:
+// context.Context - Context cancelled by the first signal.
+// func() os.Signal - Returns the first signal received, or nil.
+func handleSignals() (context.Context, func() os.Signal) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var first atomic.Value
+
+	signal.Ignore(syscall.SIGPIPE)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signals
+		first.Store(sig)
+		fmt.Fprintf(os.Stderr, "[*] Received %s, finishing queued lines and flushing output; send it again to quit immediately.\n", sig)
+		cancel()
+
+		sig = <-signals
+		fmt.Fprintf(os.Stderr, "[!] Received %s during shutdown, quitting without flushing output.\n", sig)
+		os.Exit(exitForced)
+	}()
+
+	return ctx, func() os.Signal {
+		sig, _ := first.Load().(os.Signal)
+		return sig
 	}
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		sig  os.Signal
+		want int
+	}{
+		{"broken pipe", fmt.Errorf("error writing output: %w", syscall.EPIPE), nil, 141},
+		{"broken pipe during shutdown", fmt.Errorf("error writing output: %w", syscall.EPIPE), syscall.SIGINT, 141},
+		{"interrupt", context.Canceled, syscall.SIGINT, 130},
+		{"terminate", fmt.Errorf("stopped: %w", context.Canceled), syscall.SIGTERM, 143},
+		{"cancelled without a signal", context.Canceled, nil, 1},
+		{"other error", errors.New("disk full"), syscall.SIGINT, 1},
+	}
+
+	for _, tc := range cases {
+		if got := exitStatus(tc.err, tc.sig); got != tc.want {
+			t.Errorf("%s: exitStatus = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if err := os.WriteFile("train", []byte("corpus\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"bloom", "-o", "list.bloom", "list.txt"}, "bloom"},
+		{[]string{"-w", "2", "bloom"}, ""},
+		{[]string{"--", "bloom"}, ""},
+		{[]string{"./bloom"}, ""},
+		// An existing file named like a subcommand is an input.
+		{[]string{"train"}, ""},
+	}
+
+	for _, tc := range cases {
+		if got := subcommand(tc.args); got != tc.want {
+			t.Errorf("subcommand(%q) = %q, want %q", tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package mutate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// maxAffixDigits caps the width of "digits" affix specs, which grow tenfold
+// with every extra digit.
+const maxAffixDigits = 6
+
+// defaultSpecials is the built-in list used by the "specials" affix spec.
+var defaultSpecials = []string{
+	"!", "@", "#", "$", "%", "&", "*", "?", ".", "_", "-", "+", "=", "~", "!!", "!@#",
+}
+
+// ParseAffixSpecs expands affix specifications into a de-duplicated list of
+// affixes. Each spec is one or more terms joined by "+", whose lists are
+// combined in order, so "years:1950-2030+specials" yields "1950!",
+// "1950@", and so on. The supported terms are:
+//
+//	digits:N-M  - Every digit string of length N to M (for example, 00-99 for 2-2).
+//	num:A-B     - The numbers A to B without padding.
+//	years:A-B   - The years A to B (an alias of num).
+//	specials    - The built-in list of common special characters.
+//	file:PATH   - One affix per line of a file.
+//	lit:TEXT    - The literal text.
+//
+// Args:
+// specs ([]string): Affix specifications.
+//
+// Returns:
+// []string: Expanded affixes in spec order.
+// error: Error if a spec is malformed or a file cannot be read.
+func ParseAffixSpecs(specs []string) ([]string, error) {
+	var affixes []string
+	seen := make(map[string]struct{})
+
+	for _, spec := range specs {
+		combined := []string{""}
+
+		for _, term := range strings.Split(spec, "+") {
+			values, err := parseAffixTerm(term)
+			if err != nil {
+				return nil, fmt.Errorf("invalid affix spec %q: %w", spec, err)
+			}
+
+			next := make([]string, 0, len(combined)*len(values))
+			for _, head := range combined {
+				for _, tail := range values {
+					next = append(next, head+tail)
+				}
+			}
+
+			combined = next
+		}
+
+		for _, affix := range combined {
+			if _, exists := seen[affix]; exists || affix == "" {
+				continue
+			}
+
+			seen[affix] = struct{}{}
+			affixes = append(affixes, affix)
+		}
+	}
+
+	return affixes, nil
+}
+
+// parseAffixTerm expands a single affix term.
+//
+// Args:
+// term (string): Term such as "years:1950-2030" or "specials".
+//
+// Returns:
+// []string: Affixes named by the term.
+// error: Error if the term is malformed.
+func parseAffixTerm(term string) ([]string, error) {
+	kind, arg, _ := strings.Cut(term, ":")
+
+	switch kind {
+	case "specials":
+		return append([]string(nil), defaultSpecials...), nil
+
+	case "lit":
+		if arg == "" {
+			return nil, fmt.Errorf("lit requires text")
+		}
+		return []string{arg}, nil
+
+	case "file":
+		return readAffixFile(arg)
+
+	case "num", "years":
+		start, end, err := parseAffixRange(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]string, 0, end-start+1)
+		for n := start; n <= end; n++ {
+			values = append(values, strconv.Itoa(n))
+		}
+
+		return values, nil
+
+	case "digits":
+		start, end, err := parseAffixRange(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if start < 1 || end > maxAffixDigits {
+			return nil, fmt.Errorf("digits widths must be between 1 and %d", maxAffixDigits)
+		}
+
+		var values []string
+		for width := start; width <= end; width++ {
+			limit := 1
+			for i := 0; i < width; i++ {
+				limit *= 10
+			}
+
+			for n := 0; n < limit; n++ {
+				values = append(values, fmt.Sprintf("%0*d", width, n))
+			}
+		}
+
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unknown affix term %q", term)
+}
+
+// parseAffixRange parses a non-negative range in the form "start-end".
+//
+// Args:
+// value (string): Raw range.
+//
+// Returns:
+// int: Range start.
+// int: Range end.
+// error: Error if the range is malformed.
+func parseAffixRange(value string) (int, int, error) {
+	startText, endText, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, fmt.Errorf("range %q must be in the form start-end", value)
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(startText))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range start %q: %w", startText, err)
+	}
+
+	end, err := strconv.Atoi(strings.TrimSpace(endText))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid range end %q: %w", endText, err)
+	}
+
+	if start < 0 || start > end {
+		return 0, 0, fmt.Errorf("range %q must satisfy 0 <= start <= end", value)
+	}
+
+	return start, end, nil
+}
+
+// readAffixFile reads one affix per non-empty line of a file.
+//
+// Args:
+// path (string): File path.
+//
+// Returns:
+// []string: Affixes in file order.
+// error: Error if the file cannot be read.
+func readAffixFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open affix file: %w", err)
+	}
+	defer file.Close()
+
+	var values []string
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line != "" {
+			values = append(values, line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read affix file: %w", err)
+	}
+
+	return values, nil
+}
+
+// expandAffixes appends prefixed and suffixed variants of each candidate.
+// Affixes count towards the output length range: a variant is only
+// generated when the combined length falls within cfg.OutMinLength and
+// cfg.OutMaxLength, so bases that are too short on their own can still
+// produce variants and no overlong variant is ever built.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+// candidates ([]string): Candidates to expand.
+//
+// Returns:
+// []string: The candidates followed by their affixed variants.
+func expandAffixes(cfg *structs.Config, candidates []string) []string {
+	if len(cfg.Prefixes) == 0 && len(cfg.Suffixes) == 0 {
+		return candidates
+	}
+
+	expanded := make([]string, 0, len(candidates)*2)
+
+	for _, candidate := range candidates {
+		expanded = append(expanded, candidate)
+
+		if len(candidate) >= cfg.OutMaxLength {
+			continue
+		}
+
+		for _, prefix := range cfg.Prefixes {
+			if fitsLengthRange(cfg, len(prefix)+len(candidate)) {
+				expanded = append(expanded, prefix+candidate)
+			}
+		}
+
+		for _, suffix := range cfg.Suffixes {
+			if fitsLengthRange(cfg, len(candidate)+len(suffix)) {
+				expanded = append(expanded, candidate+suffix)
+			}
+		}
+	}
+
+	return expanded
+}
+
+// fitsLengthRange reports whether a length lies within the output range.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+// length (int): Candidate length in bytes.
+//
+// Returns:
+// bool: True if the length is within range.
+func fitsLengthRange(cfg *structs.Config, length int) bool {
+	return length >= cfg.OutMinLength && length <= cfg.OutMaxLength
+}
@@ -0,0 +1,166 @@
+package mutate
+
+import (
+	"iter"
+	"sync"
+)
+
+// Batch limits. The reader closes a batch once it holds maxBatchLines lines
+// or maxBatchBytes bytes, whichever comes first, so that per-task overhead
+// such as channel sends, reorder bookkeeping, and output locking is paid
+// once for many lines.
+const (
+	maxBatchLines = 256
+	maxBatchBytes = 256 << 10
+)
+
+// lineBatch is a run of consecutive raw input lines queued for a worker.
+// The lines are stored back to back in Data without their newlines; line i
+// ends at Ends[i] and starts where line i-1 ends. Seq numbers the batches of
+// a run consecutively from zero across all sources, and End is the input
+// position just past the last line.
+type lineBatch struct {
+	Seq  uint64
+	Data []byte
+	Ends []int
+	End  inputPosition
+}
+
+// batchPool recycles line batches between the reader and the workers.
+var batchPool = sync.Pool{
+	New: func() any {
+		return &lineBatch{
+			Data: make([]byte, 0, 64<<10),
+			Ends: make([]int, 0, maxBatchLines),
+		}
+	},
+}
+
+// newLineBatch returns an empty batch from the pool.
+//
+// Returns:
+// *lineBatch - Empty batch.
+func newLineBatch() *lineBatch {
+	return batchPool.Get().(*lineBatch)
+}
+
+// releaseLineBatch returns a batch to the pool. The batch and its lines must
+// not be used afterwards.
+//
+// Args:
+// b: *lineBatch - Batch to release.
+func releaseLineBatch(b *lineBatch) {
+	b.Seq, b.End = 0, inputPosition{}
+	b.Data, b.Ends = b.Data[:0], b.Ends[:0]
+	batchPool.Put(b)
+}
+
+// Append adds a line to the batch.
+//
+// Args:
+// line: []byte - Raw line without its trailing newline.
+func (b *lineBatch) Append(line []byte) {
+	b.Data = append(b.Data, line...)
+	b.Ends = append(b.Ends, len(b.Data))
+}
+
+// Len returns the number of lines in the batch.
+//
+// Returns:
+// int - Line count.
+func (b *lineBatch) Len() int {
+	return len(b.Ends)
+}
+
+// Full reports whether the batch has reached a batch limit.
+//
+// Args:
+// maxLines: int - Maximum number of lines per batch.
+//
+// Returns:
+// bool - True if no further line should be added.
+func (b *lineBatch) Full(maxLines int) bool {
+	return len(b.Ends) >= maxLines || len(b.Data) >= maxBatchBytes
+}
+
+// Lines returns the lines of the batch in input order. The slices alias the
+// batch and are only valid until it is released.
+//
+// Returns:
+// iter.Seq[[]byte] - Sequence of raw lines.
+func (b *lineBatch) Lines() iter.Seq[[]byte] {
+	return func(yield func([]byte) bool) {
+		start := 0
+
+		for _, end := range b.Ends {
+			if !yield(b.Data[start:end:end]) {
+				return
+			}
+
+			start = end
+		}
+	}
+}
+
+// outputChunk holds the newline-delimited candidates a worker produced for
+// one batch, without a trailing newline.
+type outputChunk struct {
+	data []byte
+}
+
+// chunkPool recycles output chunks between the workers and the output sink.
+var chunkPool = sync.Pool{
+	New: func() any {
+		return &outputChunk{data: make([]byte, 0, 64<<10)}
+	},
+}
+
+// newOutputChunk returns an empty chunk from the pool.
+//
+// Returns:
+// *outputChunk - Empty chunk.
+func newOutputChunk() *outputChunk {
+	return chunkPool.Get().(*outputChunk)
+}
+
+// releaseOutputChunk returns a chunk to the pool. Sinks copy what they keep,
+// so a chunk may be released as soon as Emit returns.
+//
+// Args:
+// c: *outputChunk - Chunk to release.
+func releaseOutputChunk(c *outputChunk) {
+	c.data = c.data[:0]
+	chunkPool.Put(c)
+}
+
+// AppendCandidates adds candidates to the chunk.
+//
+// Args:
+// candidates: []string - Candidates to add.
+func (c *outputChunk) AppendCandidates(candidates []string) {
+	for _, candidate := range candidates {
+		if len(c.data) > 0 {
+			c.data = append(c.data, '\n')
+		}
+
+		c.data = append(c.data, candidate...)
+	}
+}
+
+// batchLines returns the number of lines per batch. In ordered mode a batch
+// never exceeds the reorder window, so that the window still bounds the
+// number of lines in flight.
+//
+// Args:
+// ordered: bool - Whether results are reordered.
+// window: int - Reorder window in lines.
+//
+// Returns:
+// int - Maximum number of lines per batch.
+func batchLines(ordered bool, window int) int {
+	if ordered {
+		return max(1, min(maxBatchLines, window))
+	}
+
+	return maxBatchLines
+}
@@ -0,0 +1,331 @@
+package mutate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointInterval is the time between checkpoints. Each checkpoint
+// flushes the output, so a short interval costs throughput.
+const checkpointInterval = 10 * time.Second
+
+// inputPosition identifies a point in the inputs of a run: the index of a
+// source and a byte offset into its decompressed stream.
+type inputPosition struct {
+	Source int
+	Offset int64
+}
+
+// checkpointInput records how far one input has been processed. Offset is
+// the decompressed byte offset just past the last committed line.
+type checkpointInput struct {
+	Name   string `json:"name"`
+	Offset int64  `json:"offset,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+}
+
+// checkpointState is the content of a checkpoint file. OutputStart is the
+// size the output file had before the first run wrote to it, and
+// OutputBytes the number of bytes the runs have written since, holding
+// exactly the candidates of the committed lines.
+type checkpointState struct {
+	OutputStart int64             `json:"output_start,omitempty"`
+	OutputBytes int64             `json:"output_bytes"`
+	Inputs      []checkpointInput `json:"inputs"`
+}
+
+// loadCheckpoint reads a checkpoint file.
+//
+// Args:
+// path: string - Checkpoint file path.
+//
+// Returns:
+// *checkpointState - Saved state, or nil if the file does not exist.
+// error - Error if the file cannot be read or parsed.
+func loadCheckpoint(path string) (*checkpointState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint file: %w", err)
+	}
+
+	var state checkpointState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint file: %w", err)
+	}
+
+	return &state, nil
+}
+
+// writeCheckpoint saves a checkpoint file. The state is written to a
+// temporary file first and renamed over the old checkpoint, so a crash
+// never leaves a truncated checkpoint behind.
+//
+// Args:
+// path: string - Checkpoint file path.
+// state: checkpointState - State to save.
+//
+// Returns:
+// error - Error if the file cannot be written.
+func writeCheckpoint(path string, state checkpointState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+
+	if err := os.Rename(temp, path); err != nil {
+		return fmt.Errorf("failed to write checkpoint file: %w", err)
+	}
+
+	return nil
+}
+
+// resumePosition returns the position at which a run over the given sources
+// continues from the saved state.
+//
+// Args:
+// sources: []inputSource - Inputs of the run.
+//
+// Returns:
+// inputPosition - First position not yet committed.
+// error - Error if the checkpoint was written for different inputs.
+func (s *checkpointState) resumePosition(sources []inputSource) (inputPosition, error) {
+	if len(s.Inputs) > len(sources) {
+		return inputPosition{}, fmt.Errorf("checkpoint lists %d inputs but the run has %d", len(s.Inputs), len(sources))
+	}
+
+	position := inputPosition{}
+
+	for i, input := range s.Inputs {
+		if input.Name != sources[i].Name {
+			return inputPosition{}, fmt.Errorf("checkpoint input %d is %q but the run reads %q", i+1, input.Name, sources[i].Name)
+		}
+
+		position = inputPosition{Source: i, Offset: input.Offset}
+		if input.Done {
+			position = inputPosition{Source: i + 1}
+		}
+	}
+
+	return position, nil
+}
+
+// newCheckpointState builds the state to save for a committed position.
+//
+// Args:
+// sources: []inputSource - Inputs of the run.
+// committed: inputPosition - Position just past the last committed line.
+// outputStart: int64 - Output file size before the first run.
+// outputBytes: int64 - Bytes written since, holding the committed candidates.
+//
+// Returns:
+// checkpointState - State to save.
+func newCheckpointState(sources []inputSource, committed inputPosition, outputStart, outputBytes int64) checkpointState {
+	state := checkpointState{OutputStart: outputStart, OutputBytes: outputBytes}
+
+	for i := 0; i < committed.Source && i < len(sources); i++ {
+		state.Inputs = append(state.Inputs, checkpointInput{Name: sources[i].Name, Done: true})
+	}
+
+	if committed.Source < len(sources) {
+		state.Inputs = append(state.Inputs, checkpointInput{Name: sources[committed.Source].Name, Offset: committed.Offset})
+	}
+
+	return state
+}
+
+// flusher is implemented by output sinks that write candidates as they are
+// emitted and can push buffered output to the underlying writer.
+type flusher interface {
+	Flush() error
+}
+
+// checkpointer periodically records the committed input position of a run
+// together with the size of the flushed output. outputStart is the output
+// size before the first run and baseOutput the bytes written by earlier
+// runs.
+type checkpointer struct {
+	path        string
+	sources     []inputSource
+	reorder     *reorderBuffer
+	sink        flusher
+	output      *countingWriter
+	outputStart int64
+	baseOutput  int64
+	fail        func(error)
+	stop        chan struct{}
+	wg          sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// start begins writing checkpoints until Stop is called. A failed checkpoint
+// is passed to fail, which stops the run.
+func (c *checkpointer) start() {
+	c.stop = make(chan struct{})
+	c.wg.Add(1)
+
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				if err := c.Save(); err != nil {
+					c.fail(err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends periodic checkpoints and waits for a checkpoint in progress.
+func (c *checkpointer) Stop() {
+	close(c.stop)
+	c.wg.Wait()
+}
+
+// Save flushes the output and records the committed position. The reorder
+// buffer is held while flushing, so the output holds exactly the candidates
+// of the committed lines.
+//
+// Returns:
+// error - Error if flushing or writing the checkpoint fails.
+func (c *checkpointer) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return c.err
+	}
+
+	var (
+		committed inputPosition
+		written   int64
+	)
+
+	c.err = c.reorder.Committed(func(position inputPosition) error {
+		if err := c.sink.Flush(); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+
+		committed, written = position, c.output.count.Load()
+
+		return nil
+	})
+
+	if c.err == nil {
+		c.err = writeCheckpoint(c.path, newCheckpointState(c.sources, committed, c.outputStart, c.baseOutput+written))
+	}
+
+	return c.err
+}
+
+// countingWriter counts the bytes written to an output.
+type countingWriter struct {
+	writer io.Writer
+	count  atomic.Int64
+}
+
+// Write writes to the underlying writer and counts the bytes accepted.
+//
+// Args:
+// p: []byte - Data to write.
+//
+// Returns:
+// int - Number of bytes written.
+// error - Error returned by the underlying writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.count.Add(int64(n))
+
+	return n, err
+}
+
+// outputFileSize returns the current size of an output that is a regular
+// file, so that a checkpoint can tell the content that existed before the
+// run, for example when appending with >>, from the candidates it wrote.
+//
+// Args:
+// w: io.Writer - Output of the run.
+//
+// Returns:
+// int64 - File size, or 0 when the output is not a regular file.
+func outputFileSize(w io.Writer) int64 {
+	file, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+
+	return info.Size()
+}
+
+// prepareResumeOutput cuts a regular-file output back to the size recorded
+// by the checkpoint, its size before the first run plus the bytes written
+// up to the checkpoint, dropping candidates written after it. Content that
+// preceded the first run is kept. Output that is not a regular file cannot
+// be cut, so candidates written after the last checkpoint may appear twice.
+//
+// Args:
+// path: string - Checkpoint file path.
+// output: *os.File - Output the run appends to.
+//
+// Returns:
+// error - Error if the output is shorter than the checkpoint or cannot be
+// truncated.
+func prepareResumeOutput(path string, output *os.File) error {
+	state, err := loadCheckpoint(path)
+	if err != nil || state == nil {
+		return err
+	}
+
+	info, err := output.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat output: %w", err)
+	}
+
+	if !info.Mode().IsRegular() {
+		fmt.Fprintf(os.Stderr, "[*] Output is not a regular file; candidates written after the last checkpoint may repeat.\n")
+		return nil
+	}
+
+	end := state.OutputStart + state.OutputBytes
+
+	if info.Size() < end {
+		return fmt.Errorf("output holds %d bytes but the checkpoint recorded %d; append to the original output with >>", info.Size(), end)
+	}
+
+	if info.Size() > end {
+		if err := output.Truncate(end); err != nil {
+			return fmt.Errorf("failed to truncate output to the checkpoint: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package mutate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+func TestCheckpointStateResumePosition(t *testing.T) {
+	sources := []inputSource{{Name: "a.txt"}, {Name: "b.txt"}, {Name: "c.txt"}}
+
+	state := newCheckpointState(sources, inputPosition{Source: 1, Offset: 42}, 10, 100)
+	if len(state.Inputs) != 2 || !state.Inputs[0].Done || state.Inputs[1].Offset != 42 {
+		t.Fatalf("unexpected inputs %+v", state.Inputs)
+	}
+
+	position, err := state.resumePosition(sources)
+	if err != nil {
+		t.Fatalf("resumePosition: %v", err)
+	}
+	if position != (inputPosition{Source: 1, Offset: 42}) {
+		t.Fatalf("position %+v, want source 1 offset 42", position)
+	}
+
+	done := newCheckpointState(sources, inputPosition{Source: 3}, 0, 0)
+	if position, _ := done.resumePosition(sources); position != (inputPosition{Source: 3}) {
+		t.Fatalf("finished run resumes at %+v", position)
+	}
+
+	if _, err := state.resumePosition([]inputSource{{Name: "a.txt"}, {Name: "other.txt"}}); err == nil {
+		t.Fatal("checkpoint for different inputs accepted")
+	}
+}
+
+func TestPrepareResumeOutputKeepsPriorContent(t *testing.T) {
+	dir := t.TempDir()
+	checkpoint := filepath.Join(dir, "run.ckpt")
+	outputPath := filepath.Join(dir, "out.txt")
+
+	prior := "existing\n"
+	written := "One\nTwo\n"
+	if err := os.WriteFile(outputPath, []byte(prior+written+"Three\nFo"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := checkpointState{OutputStart: int64(len(prior)), OutputBytes: int64(len(written))}
+	if err := writeCheckpoint(checkpoint, state); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	if err := prepareResumeOutput(checkpoint, output); err != nil {
+		t.Fatalf("prepareResumeOutput: %v", err)
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != prior+written {
+		t.Fatalf("output %q, want %q", got, prior+written)
+	}
+
+	state.OutputBytes = 1 << 20
+	if err := writeCheckpoint(checkpoint, state); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareResumeOutput(checkpoint, output); err == nil {
+		t.Fatal("output shorter than the checkpoint accepted")
+	}
+}
+
+func TestCheckpointRecordsAppendedOutputStart(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	checkpoint := filepath.Join(dir, "run.ckpt")
+	outputPath := filepath.Join(dir, "out.txt")
+
+	if err := os.WriteFile(input, []byte("hello world\nthe lazy river\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prior := "existing candidate\n"
+	if err := os.WriteFile(outputPath, []byte(prior), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	cfg := &structs.Config{NGramMin: 1, NGramMax: 2, OutMinLength: 1, OutMaxLength: 64, Checkpoint: checkpoint}
+	if _, err := NewPipeline(cfg).RunFiles(context.Background(), []string{input}, output); err != nil {
+		t.Fatalf("RunFiles: %v", err)
+	}
+
+	state, err := loadCheckpoint(checkpoint)
+	if err != nil || state == nil {
+		t.Fatalf("loadCheckpoint: %v", err)
+	}
+
+	info, err := output.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.OutputStart != int64(len(prior)) {
+		t.Fatalf("output start %d, want %d", state.OutputStart, len(prior))
+	}
+	if state.OutputStart+state.OutputBytes != info.Size() {
+		t.Fatalf("checkpoint covers %d bytes, output holds %d", state.OutputStart+state.OutputBytes, info.Size())
+	}
+	if state.OutputBytes == 0 {
+		t.Fatal("checkpoint recorded no output")
+	}
+	if len(state.Inputs) != 1 || (!state.Inputs[0].Done && state.Inputs[0].Offset != 27) {
+		t.Fatalf("inputs %+v, want the whole input committed", state.Inputs)
+	}
+
+	// Resuming a finished run keeps the prior content and writes nothing.
+	cfg.Resume = true
+	if err := prepareResumeOutput(checkpoint, output); err != nil {
+		t.Fatalf("prepareResumeOutput: %v", err)
+	}
+	if _, err := NewPipeline(cfg).RunFiles(context.Background(), []string{input}, output); err != nil {
+		t.Fatalf("resumed RunFiles: %v", err)
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(got), prior) || int64(len(got)) != info.Size() {
+		t.Fatalf("resumed output %q", got)
+	}
+}
+
+func TestResumeSkipsCommittedInput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	checkpoint := filepath.Join(dir, "run.ckpt")
+
+	if err := os.WriteFile(input, []byte("alpha\nbravo\ncharlie\ndelta\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := checkpointState{Inputs: []checkpointInput{{Name: input, Offset: int64(len("alpha\nbravo\n"))}}}
+	if err := writeCheckpoint(checkpoint, state); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &structs.Config{NGramMin: 1, NGramMax: 1, OutMinLength: 1, OutMaxLength: 64, Checkpoint: checkpoint, Resume: true}
+
+	var output strings.Builder
+	if _, err := NewPipeline(cfg).RunFiles(context.Background(), []string{input}, &output); err != nil {
+		t.Fatalf("RunFiles: %v", err)
+	}
+
+	if got := output.String(); got != "charlie\ndelta\n" {
+		t.Fatalf("resumed output %q, want charlie and delta only", got)
+	}
+
+	if _, err := NewPipeline(cfg).RunFiles(context.Background(), []string{filepath.Join(dir, "other.txt")}, &output); err == nil {
+		t.Fatal("resume with different inputs accepted")
+	}
+}
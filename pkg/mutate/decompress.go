@@ -0,0 +1,110 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Magic byte prefixes of the supported compression formats.
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte{'B', 'Z', 'h'}
+	// bzip2BlockMagic starts the first compressed block; bzip2EndMagic
+	// follows the header directly in an empty stream.
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+	xzMagic         = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic       = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// bzip2HeaderLen is the number of leading bytes needed to recognise a bzip2
+// stream: "BZh", the block-size level and the six-byte block magic. It is
+// also the longest prefix any supported format needs.
+const bzip2HeaderLen = len("BZh") + 1 + 6
+
+// isBzip2Header reports whether header starts a bzip2 stream. Plain text may
+// well begin with "BZh", so the level digit and the block (or end-of-stream)
+// magic that follows it are checked too.
+//
+// Args:
+// header: []byte - Leading bytes of the stream.
+//
+// Returns:
+// bool - True when the bytes form a bzip2 stream header.
+func isBzip2Header(header []byte) bool {
+	if len(header) < bzip2HeaderLen || !bytes.HasPrefix(header, bzip2Magic) {
+		return false
+	}
+
+	level := header[len(bzip2Magic)]
+	if level < '1' || level > '9' {
+		return false
+	}
+
+	magic := header[len(bzip2Magic)+1 : bzip2HeaderLen]
+
+	return bytes.Equal(magic, bzip2BlockMagic) || bytes.Equal(magic, bzip2EndMagic)
+}
+
+// openDecompressor sniffs the leading bytes of a stream and, when they match
+// a supported compression format, wraps the stream in the matching
+// decompressor. Uncompressed input is returned unchanged apart from
+// buffering. Concatenated gzip members are read as one continuous stream.
+//
+// Args:
+// source: io.Reader - Possibly compressed input.
+// size: int - Read buffer size in bytes.
+//
+// Returns:
+// io.Reader - Reader yielding decompressed bytes.
+// func() - Function releasing decompressor resources; safe to call once.
+// error - Error if the stream header is malformed.
+func openDecompressor(source io.Reader, size int) (io.Reader, func(), error) {
+	buffered := bufio.NewReaderSize(source, size)
+	noop := func() {}
+
+	header, err := buffered.Peek(bzip2HeaderLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return nil, noop, fmt.Errorf("failed to read input header: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		gz.Multistream(true)
+
+		return gz, func() { _ = gz.Close() }, nil
+
+	case isBzip2Header(header):
+		return bzip2.NewReader(buffered), noop, nil
+
+	case bytes.HasPrefix(header, xzMagic):
+		xzReader, err := xz.NewReader(buffered)
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to open xz stream: %w", err)
+		}
+
+		return xzReader, noop, nil
+
+	case bytes.HasPrefix(header, zstdMagic):
+		zr, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, noop, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+
+		return zr, zr.Close, nil
+	}
+
+	return buffered, noop, nil
+}
@@ -0,0 +1,70 @@
+package mutate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func TestOpenDecompressorPlainTextStartingWithBZh(t *testing.T) {
+	input := "BZh is not a bzip2 header here\nsecond line\n"
+
+	reader, release, err := openDecompressor(bytes.NewBufferString(input), 4096)
+	if err != nil {
+		t.Fatalf("openDecompressor: %v", err)
+	}
+	defer release()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if string(got) != input {
+		t.Fatalf("got %q, want %q", got, input)
+	}
+}
+
+func TestOpenDecompressorGzip(t *testing.T) {
+	var compressed bytes.Buffer
+
+	gz := gzip.NewWriter(&compressed)
+	_, _ = gz.Write([]byte("hello world\n"))
+	_ = gz.Close()
+
+	reader, release, err := openDecompressor(&compressed, 4096)
+	if err != nil {
+		t.Fatalf("openDecompressor: %v", err)
+	}
+	defer release()
+
+	got, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if string(got) != "hello world\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestIsBzip2Header(t *testing.T) {
+	cases := []struct {
+		name   string
+		header []byte
+		want   bool
+	}{
+		{"block", []byte("BZh9\x31\x41\x59\x26\x53\x59"), true},
+		{"empty stream", []byte("BZh1\x17\x72\x45\x38\x50\x90"), true},
+		{"bad level", []byte("BZh0\x31\x41\x59\x26\x53\x59"), false},
+		{"text", []byte("BZh9 plain text"), false},
+		{"short", []byte("BZh9"), false},
+	}
+
+	for _, tc := range cases {
+		if got := isBzip2Header(tc.header); got != tc.want {
+			t.Errorf("%s: isBzip2Header = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package mutate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Deduplication strategies accepted by structs.Config.Dedup.
+const (
+	DedupOff   = ""
+	DedupExact = "exact"
+	DedupBloom = "bloom"
+	DedupDisk  = "disk"
+)
+
+// deduplicator reports whether a candidate is being seen for the first time.
+// Implementations are safe for concurrent use.
+type deduplicator interface {
+	Add(candidate []byte) bool
+}
+
+// newDeduplicator returns the in-memory deduplicator selected by the
+// configuration, or nil when deduplication is off or handled on disk.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// deduplicator - Selected deduplicator, or nil.
+// error - Error if the strategy or its parameters are invalid.
+func newDeduplicator(cfg *structs.Config) (deduplicator, error) {
+	switch cfg.Dedup {
+	case DedupOff, DedupDisk:
+		return nil, nil
+	case DedupExact:
+		return newExactSet(), nil
+	case DedupBloom:
+		return newBloomFilter(cfg.DedupCapacity, cfg.DedupFalsePositive)
+	}
+
+	return nil, fmt.Errorf("unknown dedup strategy %q", cfg.Dedup)
+}
+
+// exactSetShards is the number of independently locked shards of an exactSet.
+const exactSetShards = 64
+
+// exactSet is a sharded in-memory hash set that remembers every candidate.
+type exactSet struct {
+	seed   maphash.Seed
+	shards [exactSetShards]struct {
+		mu   sync.Mutex
+		seen map[string]struct{}
+	}
+}
+
+// newExactSet creates an empty exactSet.
+//
+// Returns:
+// *exactSet - Empty set.
+func newExactSet() *exactSet {
+	s := &exactSet{seed: maphash.MakeSeed()}
+
+	for i := range s.shards {
+		s.shards[i].seen = make(map[string]struct{})
+	}
+
+	return s
+}
+
+// Add inserts a candidate and reports whether it was not already present.
+//
+// Args:
+// candidate: []byte - Candidate to insert.
+//
+// Returns:
+// bool - True if the candidate was new.
+func (s *exactSet) Add(candidate []byte) bool {
+	shard := &s.shards[maphash.Bytes(s.seed, candidate)%exactSetShards]
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if _, exists := shard.seen[string(candidate)]; exists {
+		return false
+	}
+
+	shard.seen[string(candidate)] = struct{}{}
+
+	return true
+}
+
+// bloomLockStripes is the number of locks serialising inserts of identical
+// candidates into a bloomFilter.
+const bloomLockStripes = 256
+
+// bloomFilter is a fixed-size probabilistic set. A candidate reported as
+// seen may be a false positive, at roughly the configured rate once the
+// expected capacity is reached; new candidates are never reported twice.
+type bloomFilter struct {
+	bits    []atomic.Uint64
+	numBits uint64
+	hashes  int
+	locks   [bloomLockStripes]sync.Mutex
+}
+
+// newBloomFilter sizes a Bloom filter for the expected number of distinct
+// candidates and the target false-positive rate.
+//
+// Args:
+// capacity: int - Expected number of distinct candidates.
+// falsePositive: float64 - Target false-positive rate, between 0 and 1.
+//
+// Returns:
+// *bloomFilter - Empty filter.
+// error - Error if the parameters are out of range.
+func newBloomFilter(capacity int, falsePositive float64) (*bloomFilter, error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("bloom capacity must be positive, got %d", capacity)
+	}
+
+	if falsePositive <= 0 || falsePositive >= 1 {
+		return nil, fmt.Errorf("bloom false-positive rate must be between 0 and 1, got %g", falsePositive)
+	}
+
+	numBits := math.Ceil(-float64(capacity) * math.Log(falsePositive) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Round(numBits / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	words := (uint64(numBits) + 63) / 64
+
+	return &bloomFilter{
+		bits:    make([]atomic.Uint64, words),
+		numBits: words * 64,
+		hashes:  hashes,
+	}, nil
+}
+
+// Add inserts a candidate and reports whether it was not already present.
+//
+// Args:
+// candidate: []byte - Candidate to insert.
+//
+// Returns:
+// bool - True if the candidate was (probably) new.
+func (f *bloomFilter) Add(candidate []byte) bool {
+	h1, h2 := bloomHashes(candidate)
+
+	lock := &f.locks[h1%bloomLockStripes]
+	lock.Lock()
+	defer lock.Unlock()
+
+	added := false
+
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.numBits
+		mask := uint64(1) << (bit % 64)
+
+		if f.bits[bit/64].Or(mask)&mask == 0 {
+			added = true
+		}
+	}
+
+	return added
+}
+
+// Contains reports whether a candidate may have been inserted.
+//
+// Args:
+// candidate: []byte - Candidate to test.
+//
+// Returns:
+// bool - False if the candidate was definitely never inserted.
+func (f *bloomFilter) Contains(candidate []byte) bool {
+	h1, h2 := bloomHashes(candidate)
+
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % f.numBits
+		mask := uint64(1) << (bit % 64)
+
+		if f.bits[bit/64].Load()&mask == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// bloomFileMagic identifies serialised Bloom filter files.
+const bloomFileMagic = "BSBLOOM1"
+
+// WriteTo serialises the filter as the magic string, the number of bits
+// and hash functions, and the little-endian bit words.
+//
+// Args:
+// w: io.Writer - Destination.
+//
+// Returns:
+// int64 - Number of bytes written.
+// error - Error if writing fails.
+func (f *bloomFilter) WriteTo(w io.Writer) (int64, error) {
+	writer := bufio.NewWriterSize(w, 1<<20)
+
+	header := make([]byte, 0, len(bloomFileMagic)+12)
+	header = append(header, bloomFileMagic...)
+	header = binary.LittleEndian.AppendUint64(header, f.numBits)
+	header = binary.LittleEndian.AppendUint32(header, uint32(f.hashes))
+
+	written, _ := writer.Write(header)
+
+	var word [8]byte
+	for i := range f.bits {
+		binary.LittleEndian.PutUint64(word[:], f.bits[i].Load())
+		n, _ := writer.Write(word[:])
+		written += n
+	}
+
+	return int64(written), writer.Flush()
+}
+
+// readBloomFilter loads a filter written by WriteTo.
+//
+// Args:
+// r: io.Reader - Serialised filter.
+//
+// Returns:
+// *bloomFilter - Loaded filter.
+// error - Error if the data is not a valid filter.
+func readBloomFilter(r io.Reader) (*bloomFilter, error) {
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	header := make([]byte, len(bloomFileMagic)+12)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read bloom filter header: %w", err)
+	}
+
+	if string(header[:len(bloomFileMagic)]) != bloomFileMagic {
+		return nil, errors.New("not a brainstorm bloom filter file")
+	}
+
+	numBits := binary.LittleEndian.Uint64(header[len(bloomFileMagic):])
+	hashes := int(binary.LittleEndian.Uint32(header[len(bloomFileMagic)+8:]))
+
+	if numBits == 0 || numBits%64 != 0 || hashes < 1 {
+		return nil, errors.New("corrupt bloom filter header")
+	}
+
+	f := &bloomFilter{
+		bits:    make([]atomic.Uint64, numBits/64),
+		numBits: numBits,
+		hashes:  hashes,
+	}
+
+	var word [8]byte
+	for i := range f.bits {
+		if _, err := io.ReadFull(reader, word[:]); err != nil {
+			return nil, fmt.Errorf("failed to read bloom filter bits: %w", err)
+		}
+		f.bits[i].Store(binary.LittleEndian.Uint64(word[:]))
+	}
+
+	return f, nil
+}
+
+// BuildBloomFile builds a Bloom filter holding every line of the given
+// wordlists and writes it to a file for use as a "bloom:" exclude list. The
+// wordlists are read twice: once to size the filter and once to fill it.
+// Compressed wordlists are decompressed transparently.
+//
+// Args:
+// inputs: []string - Wordlist files.
+// falsePositive: float64 - Target false-positive rate.
+// out: string - Output file path.
+//
+// Returns:
+// int64 - Number of lines inserted.
+// error - Error if a wordlist cannot be read or the file cannot be written.
+func BuildBloomFile(inputs []string, falsePositive float64, out string) (int64, error) {
+	var total int64
+
+	err := forEachWordlistLine(inputs, func([]byte) {
+		total++
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	filter, err := newBloomFilter(int(max(total, 1)), falsePositive)
+	if err != nil {
+		return 0, err
+	}
+
+	err = forEachWordlistLine(inputs, func(line []byte) {
+		filter.Add(line)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bloom filter file: %w", err)
+	}
+
+	_, err = filter.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to write bloom filter file: %w", err)
+	}
+
+	return total, nil
+}
+
+// forEachWordlistLine calls fn for every non-empty line of the given
+// wordlists, without line endings.
+//
+// Args:
+// inputs: []string - Wordlist files.
+// fn: func([]byte) - Callback receiving each line.
+//
+// Returns:
+// error - Error if a wordlist cannot be read.
+func forEachWordlistLine(inputs []string, fn func(line []byte)) error {
+	for _, path := range inputs {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open wordlist %q: %w", path, err)
+		}
+
+		decompressed, closeDecompressor, err := openDecompressor(file, 1<<20)
+		if err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to read wordlist %q: %w", path, err)
+		}
+
+		reader := bufio.NewReaderSize(decompressed, 1<<20)
+
+		for {
+			line, readErr := reader.ReadBytes('\n')
+
+			line = trimLineEnding(line)
+			if len(line) > 0 {
+				fn(line)
+			}
+
+			if readErr == nil {
+				continue
+			}
+
+			closeDecompressor()
+			_ = file.Close()
+
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+
+			return fmt.Errorf("failed to read wordlist %q: %w", path, readErr)
+		}
+	}
+
+	return nil
+}
+
+// trimLineEnding removes a trailing "\n" or "\r\n".
+//
+// Args:
+// line: []byte - Line possibly ending in a line terminator.
+//
+// Returns:
+// []byte - Line without its terminator.
+func trimLineEnding(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+
+	return line
+}
+
+// bloomHashes derives the two base hashes used for double hashing. The hash
+// is deterministic so that filters can be compared across runs.
+//
+// Args:
+// data: []byte - Candidate bytes.
+//
+// Returns:
+// uint64 - First base hash.
+// uint64 - Second base hash, always odd.
+func bloomHashes(data []byte) (uint64, uint64) {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+
+	h := uint64(offset64)
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= prime64
+	}
+
+	// splitmix64 finaliser to derive an independent second hash.
+	z := h + 0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+
+	return h, z | 1
+}
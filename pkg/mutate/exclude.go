@@ -0,0 +1,320 @@
+package mutate
+
+import (
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Exclude list prefixes selecting how a list is loaded. A list without a
+// prefix is a plain wordlist loaded into memory.
+const (
+	ExcludeSortedPrefix = "sorted:"
+	ExcludeBloomPrefix  = "bloom:"
+)
+
+// exclusionSet reports whether a candidate belongs to an exclude list.
+// Implementations are safe for concurrent use.
+type exclusionSet interface {
+	Contains(candidate []byte) bool
+}
+
+// excludeList is a loaded exclude list and the number of candidates it
+// removed.
+type excludeList struct {
+	name    string
+	set     exclusionSet
+	release func() error
+	removed atomic.Int64
+}
+
+// excludeFilter drops candidates found in any of its exclude lists.
+type excludeFilter struct {
+	lists []*excludeList
+}
+
+// newExcludeFilter loads the exclude lists named by the configuration.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// *excludeFilter - Filter, or nil when no lists are configured.
+// error - Error if a list cannot be loaded.
+func newExcludeFilter(cfg *structs.Config) (*excludeFilter, error) {
+	if len(cfg.ExcludeLists) == 0 {
+		return nil, nil
+	}
+
+	filter := &excludeFilter{}
+
+	for _, spec := range cfg.ExcludeLists {
+		list, err := loadExcludeList(spec)
+		if err != nil {
+			_ = filter.Close()
+			return nil, err
+		}
+
+		filter.lists = append(filter.lists, list)
+	}
+
+	return filter, nil
+}
+
+// loadExcludeList loads a single exclude list according to its prefix.
+//
+// Args:
+// spec: string - List path, optionally prefixed with "sorted:" or "bloom:".
+//
+// Returns:
+// *excludeList - Loaded list.
+// error - Error if the list cannot be loaded.
+func loadExcludeList(spec string) (*excludeList, error) {
+	list := &excludeList{name: spec, release: func() error { return nil }}
+
+	switch {
+	case strings.HasPrefix(spec, ExcludeSortedPrefix):
+		set, err := openSortedFileSet(strings.TrimPrefix(spec, ExcludeSortedPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load exclude list %q: %w", spec, err)
+		}
+
+		list.set = set
+		list.release = set.release
+
+	case strings.HasPrefix(spec, ExcludeBloomPrefix):
+		file, err := os.Open(strings.TrimPrefix(spec, ExcludeBloomPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load exclude list %q: %w", spec, err)
+		}
+
+		filter, err := readBloomFilter(file)
+		_ = file.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to load exclude list %q: %w", spec, err)
+		}
+
+		list.set = filter
+
+	default:
+		set, err := loadHashedWordSet(spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load exclude list %q: %w", spec, err)
+		}
+
+		list.set = set
+	}
+
+	return list, nil
+}
+
+// Filter returns the newline-delimited candidates that are not in any
+// exclude list, crediting each removal to the first list that matched.
+//
+// Args:
+// candidates: []byte - Newline-delimited candidates.
+//
+// Returns:
+// []byte - Remaining candidates, or nil if none remain.
+func (f *excludeFilter) Filter(candidates []byte) []byte {
+	var kept []byte
+
+	for candidate := range bytes.SplitSeq(candidates, []byte{'\n'}) {
+		excluded := false
+
+		for _, list := range f.lists {
+			if list.set.Contains(candidate) {
+				list.removed.Add(1)
+				excluded = true
+				break
+			}
+		}
+
+		if excluded {
+			continue
+		}
+
+		if len(kept) > 0 {
+			kept = append(kept, '\n')
+		}
+
+		kept = append(kept, candidate...)
+	}
+
+	return kept
+}
+
+// Removed returns the number of candidates removed by each list.
+//
+// Returns:
+// []structs.ExcludeStat - Per-list removal counts, in configuration order.
+func (f *excludeFilter) Removed() []structs.ExcludeStat {
+	stats := make([]structs.ExcludeStat, 0, len(f.lists))
+
+	for _, list := range f.lists {
+		stats = append(stats, structs.ExcludeStat{List: list.name, Removed: list.removed.Load()})
+	}
+
+	return stats
+}
+
+// Close releases resources held by the lists, such as memory mappings.
+//
+// Returns:
+// error - First error encountered while releasing.
+func (f *excludeFilter) Close() error {
+	var firstErr error
+
+	for _, list := range f.lists {
+		if err := list.release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// hashedWordSet stores the 64-bit hashes of a wordlist in a sorted slice,
+// costing 8 bytes per word. Two distinct words share a hash with negligible
+// probability for any realistic list size.
+type hashedWordSet struct {
+	seed   maphash.Seed
+	hashes []uint64
+}
+
+// loadHashedWordSet reads a wordlist into a hashedWordSet.
+//
+// Args:
+// path: string - Wordlist file.
+//
+// Returns:
+// *hashedWordSet - Loaded set.
+// error - Error if the wordlist cannot be read.
+func loadHashedWordSet(path string) (*hashedWordSet, error) {
+	set := &hashedWordSet{seed: maphash.MakeSeed()}
+
+	err := forEachWordlistLine([]string{path}, func(line []byte) {
+		set.hashes = append(set.hashes, maphash.Bytes(set.seed, line))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(set.hashes)
+	set.hashes = slices.Compact(set.hashes)
+	set.hashes = slices.Clip(set.hashes)
+
+	return set, nil
+}
+
+// Contains reports whether the candidate's hash is in the set.
+//
+// Args:
+// candidate: []byte - Candidate to test.
+//
+// Returns:
+// bool - True if the candidate is (almost certainly) in the wordlist.
+func (s *hashedWordSet) Contains(candidate []byte) bool {
+	_, found := slices.BinarySearch(s.hashes, maphash.Bytes(s.seed, candidate))
+	return found
+}
+
+// sortedFileSet looks candidates up in a memory-mapped wordlist sorted in
+// byte order (LC_ALL=C sort), using binary search directly on the file
+// contents so that no per-word memory is needed.
+type sortedFileSet struct {
+	data    []byte
+	release func() error
+}
+
+// openSortedFileSet maps a sorted wordlist and verifies its order.
+//
+// Args:
+// path: string - Sorted wordlist file.
+//
+// Returns:
+// *sortedFileSet - Mapped set.
+// error - Error if the file cannot be mapped or is not sorted.
+func openSortedFileSet(path string) (*sortedFileSet, error) {
+	data, release, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []byte
+	lineNumber := 0
+
+	// Lines are checked exactly as Contains compares them, so that the
+	// binary search never skips a line the check accepted.
+	for line := range bytes.Lines(data) {
+		lineNumber++
+		line = sortedLine(line)
+
+		if lineNumber > 1 && bytes.Compare(previous, line) > 0 {
+			_ = release()
+			return nil, fmt.Errorf("line %d is out of order; sort the file with LC_ALL=C sort", lineNumber)
+		}
+
+		previous = line
+	}
+
+	return &sortedFileSet{data: data, release: release}, nil
+}
+
+// sortedLine returns a line of a sorted wordlist as it is compared: without
+// its line ending, so that files with CRLF line endings work too.
+//
+// Args:
+// line: []byte - Line, with or without its line ending.
+//
+// Returns:
+// []byte - Line contents.
+func sortedLine(line []byte) []byte {
+	return bytes.TrimSuffix(bytes.TrimSuffix(line, []byte{'\n'}), []byte{'\r'})
+}
+
+// Contains binary-searches the mapped file for a line equal to candidate.
+// The search range always starts and ends on line boundaries.
+//
+// Args:
+// candidate: []byte - Candidate to look up.
+//
+// Returns:
+// bool - True if the file contains the candidate as a whole line.
+func (s *sortedFileSet) Contains(candidate []byte) bool {
+	lo, hi := 0, len(s.data)
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start := lo
+		if i := bytes.LastIndexByte(s.data[lo:mid], '\n'); i >= 0 {
+			start = lo + i + 1
+		}
+
+		end := hi
+		if i := bytes.IndexByte(s.data[start:hi], '\n'); i >= 0 {
+			end = start + i
+		}
+
+		line := sortedLine(s.data[start:end])
+
+		switch bytes.Compare(line, candidate) {
+		case 0:
+			return true
+		case -1:
+			lo = end + 1
+		default:
+			hi = start
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,100 @@
+package mutate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeWordlist(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSortedFileSetContains(t *testing.T) {
+	words := []string{"Apple", "apple", "banana", "cherry", "date", "elder", "fig", "grape"}
+
+	var content string
+	for _, word := range words {
+		content += word + "\n"
+	}
+
+	set, err := openSortedFileSet(writeWordlist(t, content))
+	if err != nil {
+		t.Fatalf("openSortedFileSet: %v", err)
+	}
+	defer set.release()
+
+	for _, word := range words {
+		if !set.Contains([]byte(word)) {
+			t.Errorf("Contains(%q) = false", word)
+		}
+	}
+
+	for _, word := range []string{"", "Aardvark", "app", "applesauce", "coconut", "zebra", "grapes"} {
+		if set.Contains([]byte(word)) {
+			t.Errorf("Contains(%q) = true", word)
+		}
+	}
+}
+
+func TestSortedFileSetCRLFAndNoTrailingNewline(t *testing.T) {
+	set, err := openSortedFileSet(writeWordlist(t, "alpha\r\nbeta\r\ngamma"))
+	if err != nil {
+		t.Fatalf("openSortedFileSet: %v", err)
+	}
+	defer set.release()
+
+	for _, word := range []string{"alpha", "beta", "gamma"} {
+		if !set.Contains([]byte(word)) {
+			t.Errorf("Contains(%q) = false", word)
+		}
+	}
+}
+
+func TestSortedFileSetRejectsUnsorted(t *testing.T) {
+	if _, err := openSortedFileSet(writeWordlist(t, "beta\nalpha\n")); err == nil {
+		t.Fatal("unsorted wordlist accepted")
+	}
+}
+
+func TestSortedFileSetChecksLinesAsCompared(t *testing.T) {
+	// Sorted with their line endings these lines are in order, since '\t'
+	// sorts before '\r', but lookups compare "a" with "a\tb".
+	if _, err := openSortedFileSet(writeWordlist(t, "a\tb\r\na\r\n")); err == nil {
+		t.Error("wordlist out of order without its CRLF endings accepted")
+	}
+
+	// An empty line compares below every word, so one after the first line
+	// would send the search past the words before it.
+	if _, err := openSortedFileSet(writeWordlist(t, "alpha\n\nbeta\n")); err == nil {
+		t.Error("wordlist with an empty line in the middle accepted")
+	}
+
+	set, err := openSortedFileSet(writeWordlist(t, "\r\nalpha\r\nbeta\r\n"))
+	if err != nil {
+		t.Fatalf("openSortedFileSet: %v", err)
+	}
+	defer set.release()
+
+	if !set.Contains([]byte("alpha")) || !set.Contains([]byte("beta")) {
+		t.Error("words after a leading empty line not found")
+	}
+}
+
+func TestHashedWordSetContains(t *testing.T) {
+	set, err := loadHashedWordSet(writeWordlist(t, "one\ntwo\nthree\n"))
+	if err != nil {
+		t.Fatalf("loadHashedWordSet: %v", err)
+	}
+
+	if !set.Contains([]byte("two")) || set.Contains([]byte("four")) {
+		t.Fatal("hashed set lookup mismatch")
+	}
+}
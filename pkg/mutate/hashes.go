@@ -0,0 +1,323 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf16"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+
+	"golang.org/x/crypto/md4"
+)
+
+// Hash algorithms accepted by structs.Config.HashAlgo.
+const (
+	HashMD5    = "md5"
+	HashSHA1   = "sha1"
+	HashSHA256 = "sha256"
+	HashSHA512 = "sha512"
+	HashNTLM   = "ntlm"
+)
+
+// hashAlgorithms maps each supported algorithm to its hash constructor.
+var hashAlgorithms = map[string]func() hash.Hash{
+	HashMD5:    md5.New,
+	HashSHA1:   sha1.New,
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+	HashNTLM:   md4.New,
+}
+
+// IsValidHashAlgo reports whether name is a supported hash algorithm.
+//
+// Args:
+// name: string - The algorithm name to check.
+//
+// Returns:
+// bool - True if the algorithm is supported.
+func IsValidHashAlgo(name string) bool {
+	_, exists := hashAlgorithms[name]
+	return exists
+}
+
+// hashMatcher hashes candidates and keeps only those matching a target
+// hash, rewriting them as potfile "hash:plaintext" lines. Each target is
+// reported once: matched digests are recorded and skipped afterwards.
+type hashMatcher struct {
+	algo    string
+	newHash func() hash.Hash
+	targets map[string]struct{}
+	cracked map[string]struct{}
+
+	mu        sync.Mutex
+	found     map[string]struct{}
+	remaining atomic.Int64
+}
+
+// newHashMatcher loads the target hashes, and the optional potfile, named
+// by the configuration. Hashes already present in the potfile are removed
+// from the targets, and their plaintexts are never matched again.
+//
+// The supported algorithms are unsalted, so a potfile line is read as
+// "hash:plaintext" split at the first colon, and the plaintext may itself
+// contain colons. Lines whose hash field is not a digest of the configured
+// algorithm, such as those of other or salted hash types, are skipped. A
+// salted "hash:salt:plaintext" line whose hash happens to have the same
+// digest size cannot be told apart and is read with "salt:plaintext" as its
+// plaintext; its hash matches no target, so at worst that string is never
+// tested.
+//
+// Args:
+// cfg: *structs.Config - Configuration.
+//
+// Returns:
+// *hashMatcher - Matcher, or nil when no hash file is configured.
+// error - Error if a file cannot be read or contains malformed hashes.
+func newHashMatcher(cfg *structs.Config) (*hashMatcher, error) {
+	if cfg.HashFile == "" {
+		return nil, nil
+	}
+
+	newHash, exists := hashAlgorithms[cfg.HashAlgo]
+	if !exists {
+		return nil, fmt.Errorf("unknown hash algorithm %q", cfg.HashAlgo)
+	}
+
+	m := &hashMatcher{
+		algo:    cfg.HashAlgo,
+		newHash: newHash,
+		targets: make(map[string]struct{}),
+		cracked: make(map[string]struct{}),
+		found:   make(map[string]struct{}),
+	}
+
+	digestSize := newHash().Size()
+
+	err := readLines(cfg.HashFile, func(line string) error {
+		digest, err := decodeHashField(line, digestSize)
+		if err != nil {
+			return err
+		}
+
+		m.targets[string(digest)] = struct{}{}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hashes from %q: %w", cfg.HashFile, err)
+	}
+
+	if cfg.PotFile != "" {
+		err := readLines(cfg.PotFile, func(line string) error {
+			hashField, plain, found := strings.Cut(line, ":")
+			if !found {
+				return nil
+			}
+
+			digest, err := decodeHashField(hashField, digestSize)
+			if err != nil {
+				// A line of another hash type, whose plaintext may not
+				// even start after the first colon.
+				return nil
+			}
+
+			delete(m.targets, string(digest))
+			m.cracked[decodePotPlain(plain)] = struct{}{}
+
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to load potfile %q: %w", cfg.PotFile, err)
+		}
+	}
+
+	m.remaining.Store(int64(len(m.targets)))
+
+	return m, nil
+}
+
+// Match hashes each candidate and returns the newline-delimited potfile
+// lines of the candidates that match a target hash not matched before.
+// Once every target has been matched, candidates are no longer hashed.
+// Safe for concurrent use.
+//
+// Args:
+// candidates: []byte - Newline-delimited candidates.
+//
+// Returns:
+// []byte - Potfile lines for matching candidates, or nil.
+func (m *hashMatcher) Match(candidates []byte) []byte {
+	if m.remaining.Load() == 0 {
+		return nil
+	}
+
+	h := m.newHash()
+	var (
+		out    []byte
+		digest []byte
+	)
+
+	for candidate := range bytes.SplitSeq(candidates, []byte{'\n'}) {
+		if _, exists := m.cracked[string(candidate)]; exists {
+			continue
+		}
+
+		h.Reset()
+
+		if m.algo == HashNTLM {
+			for _, unit := range utf16.Encode([]rune(string(candidate))) {
+				_, _ = h.Write([]byte{byte(unit), byte(unit >> 8)})
+			}
+		} else {
+			_, _ = h.Write(candidate)
+		}
+
+		digest = h.Sum(digest[:0])
+
+		if _, exists := m.targets[string(digest)]; !exists {
+			continue
+		}
+
+		if !m.claim(digest) {
+			continue
+		}
+
+		if len(out) > 0 {
+			out = append(out, '\n')
+		}
+
+		out = hex.AppendEncode(out, digest)
+		out = append(out, ':')
+		out = appendPotPlain(out, candidate)
+
+		if m.remaining.Load() == 0 {
+			break
+		}
+	}
+
+	return out
+}
+
+// claim records a matched target digest.
+//
+// Args:
+// digest: []byte - Digest of a candidate matching a target.
+//
+// Returns:
+// bool - True if the digest had not been matched before.
+func (m *hashMatcher) claim(digest []byte) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.found[string(digest)]; exists {
+		return false
+	}
+
+	m.found[string(digest)] = struct{}{}
+	m.remaining.Add(-1)
+
+	return true
+}
+
+// decodeHashField decodes the hex digest at the start of a hash-file line,
+// ignoring anything after the first colon.
+//
+// Args:
+// line: string - Hash-file line.
+// digestSize: int - Expected digest size in bytes.
+//
+// Returns:
+// []byte - Decoded digest.
+// error - Error if the digest is not valid hex of the expected size.
+func decodeHashField(line string, digestSize int) ([]byte, error) {
+	field, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+
+	digest, err := hex.DecodeString(field)
+	if err != nil || len(digest) != digestSize {
+		return nil, fmt.Errorf("invalid hash %q, expected %d hex characters", field, digestSize*2)
+	}
+
+	return digest, nil
+}
+
+// appendPotPlain appends a plaintext in potfile form, using hashcat's
+// $HEX[...] notation when it contains bytes outside printable ASCII.
+//
+// Args:
+// dst: []byte - Destination buffer.
+// plain: []byte - Plaintext.
+//
+// Returns:
+// []byte - The extended buffer.
+func appendPotPlain(dst []byte, plain []byte) []byte {
+	for _, b := range plain {
+		if b < 0x20 || b > 0x7e {
+			dst = append(dst, "$HEX["...)
+			dst = hex.AppendEncode(dst, plain)
+			return append(dst, ']')
+		}
+	}
+
+	return append(dst, plain...)
+}
+
+// decodePotPlain decodes a potfile plaintext, expanding $HEX[...] notation.
+//
+// Args:
+// plain: string - Plaintext field of a potfile line.
+//
+// Returns:
+// string - Decoded plaintext.
+func decodePotPlain(plain string) string {
+	if strings.HasPrefix(plain, "$HEX[") && strings.HasSuffix(plain, "]") {
+		if decoded, err := hex.DecodeString(plain[5 : len(plain)-1]); err == nil {
+			return string(decoded)
+		}
+	}
+
+	return plain
+}
+
+// readLines calls fn for each non-empty line of a file, without line
+// endings.
+//
+// Args:
+// path: string - File path.
+// fn: func(string) error - Callback; returning an error stops reading.
+//
+// Returns:
+// error - Error from opening or reading the file, or from fn.
+func readLines(path string, fn func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
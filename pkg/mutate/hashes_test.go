@@ -0,0 +1,127 @@
+package mutate
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHashMatcherReportsEachTargetOnce(t *testing.T) {
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "hashes.txt")
+
+	content := md5Hex("HelloWorld") + "\n" + md5Hex("Secret") + "\n"
+	if err := os.WriteFile(hashFile, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newHashMatcher(&structs.Config{HashFile: hashFile, HashAlgo: HashMD5})
+	if err != nil {
+		t.Fatalf("newHashMatcher: %v", err)
+	}
+
+	got := string(m.Match([]byte("foo\nHelloWorld\nHelloWorld")))
+	if want := md5Hex("HelloWorld") + ":HelloWorld"; got != want {
+		t.Fatalf("first match %q, want %q", got, want)
+	}
+
+	if got := m.Match([]byte("HelloWorld\nbar")); got != nil {
+		t.Fatalf("repeated plaintext matched again: %q", got)
+	}
+
+	got = string(m.Match([]byte("Secret")))
+	if !strings.HasSuffix(got, ":Secret") {
+		t.Fatalf("second target not matched: %q", got)
+	}
+
+	if m.remaining.Load() != 0 {
+		t.Fatalf("remaining = %d, want 0", m.remaining.Load())
+	}
+
+	if got := m.Match([]byte("Secret\nHelloWorld")); got != nil {
+		t.Fatalf("exhausted matcher returned %q", got)
+	}
+}
+
+func TestHashMatcherSkipsPotfileHashes(t *testing.T) {
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "hashes.txt")
+	potFile := filepath.Join(dir, "hashcat.pot")
+
+	if err := os.WriteFile(hashFile, []byte(md5Hex("cracked")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(potFile, []byte(md5Hex("cracked")+":cracked\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newHashMatcher(&structs.Config{HashFile: hashFile, HashAlgo: HashMD5, PotFile: potFile})
+	if err != nil {
+		t.Fatalf("newHashMatcher: %v", err)
+	}
+
+	if got := m.Match([]byte("cracked")); got != nil {
+		t.Fatalf("potfile entry matched again: %q", got)
+	}
+}
+
+func TestHashMatcherPotfileFields(t *testing.T) {
+	dir := t.TempDir()
+	hashFile := filepath.Join(dir, "hashes.txt")
+	potFile := filepath.Join(dir, "hashcat.pot")
+
+	if err := os.WriteFile(hashFile, []byte(md5Hex("Open")+"\n"+md5Hex("Sesame")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	pot := strings.Join([]string{
+		// A plaintext containing a colon.
+		md5Hex("a:b") + ":a:b",
+		// A salted line of another hash type, whose first field is not an
+		// MD5 digest.
+		"$2a$05$abcdefghijklmnopqrstuu:Open",
+		// A SHA1 line, whose digest has the wrong size.
+		"356a192b7913b04c54574d18c28d46e6395428ab:Sesame",
+	}, "\n") + "\n"
+
+	if err := os.WriteFile(potFile, []byte(pot), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := newHashMatcher(&structs.Config{HashFile: hashFile, HashAlgo: HashMD5, PotFile: potFile})
+	if err != nil {
+		t.Fatalf("newHashMatcher: %v", err)
+	}
+
+	if _, exists := m.cracked["a:b"]; !exists {
+		t.Errorf("plaintext with a colon not read whole: %q", m.cracked)
+	}
+
+	if len(m.cracked) != 1 {
+		t.Errorf("plaintexts of other hash types were loaded: %q", m.cracked)
+	}
+
+	want := md5Hex("Open") + ":Open\n" + md5Hex("Sesame") + ":Sesame"
+	if got := string(m.Match([]byte("Open\nSesame"))); got != want {
+		t.Fatalf("Match = %q, want %q", got, want)
+	}
+}
+
+func TestPotPlainRoundTrip(t *testing.T) {
+	for _, plain := range []string{"simple", "caf\xc3\xa9", "a:b"} {
+		encoded := string(appendPotPlain(nil, []byte(plain)))
+		if decoded := decodePotPlain(encoded); decoded != plain {
+			t.Errorf("round trip of %q gave %q via %q", plain, decoded, encoded)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package mutate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Heuristic presets accepted by HeuristicPreset.
+const (
+	HeuristicsOff     = "off"
+	HeuristicsLoose   = "loose"
+	HeuristicsDefault = "default"
+	HeuristicsStrict  = "strict"
+)
+
+// heuristicPresets maps each preset name to its thresholds. The default
+// preset holds the values the heuristics have always used.
+var heuristicPresets = map[string]structs.Heuristics{
+	HeuristicsOff: {Disabled: true},
+	HeuristicsLoose: {
+		MinVowelRatio:           0.15,
+		MaxVowelRatio:           0.9,
+		MaxConsonantRun:         6,
+		MaxVowelRun:             4,
+		LongWordLength:          10,
+		MinLongWordSyllables:    2,
+		MaxUncommonBigramRatio:  0.35,
+		MaxVowelFreeWindowRatio: 0.6,
+		MaxNonLetterRatio:       0.45,
+		MaxMixedRun:             7,
+	},
+	HeuristicsDefault: {
+		MinVowelRatio:           0.25,
+		MaxVowelRatio:           0.8,
+		MaxConsonantRun:         4,
+		MaxVowelRun:             3,
+		LongWordLength:          8,
+		MinLongWordSyllables:    2,
+		MaxUncommonBigramRatio:  0.2,
+		MaxVowelFreeWindowRatio: 0.35,
+		MaxNonLetterRatio:       0.3,
+		MaxMixedRun:             5,
+	},
+	HeuristicsStrict: {
+		MinVowelRatio:           0.3,
+		MaxVowelRatio:           0.7,
+		MaxConsonantRun:         3,
+		MaxVowelRun:             2,
+		LongWordLength:          6,
+		MinLongWordSyllables:    2,
+		MaxUncommonBigramRatio:  0.1,
+		MaxVowelFreeWindowRatio: 0.2,
+		MaxNonLetterRatio:       0.15,
+		MaxMixedRun:             3,
+	},
+}
+
+// HeuristicPreset returns the thresholds of a named preset.
+//
+// Args:
+// name: string - Preset name: off, loose, default, or strict.
+//
+// Returns:
+// structs.Heuristics - Preset thresholds.
+// error - Error if the preset is unknown.
+func HeuristicPreset(name string) (structs.Heuristics, error) {
+	preset, exists := heuristicPresets[name]
+	if !exists {
+		return structs.Heuristics{}, fmt.Errorf("unknown heuristics preset %q, expected off, loose, default, or strict", name)
+	}
+
+	return preset, nil
+}
+
+// heuristicsFor returns the thresholds to use for a configuration, falling
+// back to the default preset when none are set.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// structs.Heuristics - Thresholds in effect.
+func heuristicsFor(cfg *structs.Config) structs.Heuristics {
+	if cfg.Heuristics == (structs.Heuristics{}) {
+		return heuristicPresets[HeuristicsDefault]
+	}
+
+	return cfg.Heuristics
+}
+
+// heuristicFields maps each threshold key to a pointer to its field.
+//
+// Args:
+// h: *structs.Heuristics - Thresholds to address.
+//
+// Returns:
+// map[string]any - Pointers to the float64 and int fields, by key.
+func heuristicFields(h *structs.Heuristics) map[string]any {
+	return map[string]any{
+		"min-vowel-ratio":             &h.MinVowelRatio,
+		"max-vowel-ratio":             &h.MaxVowelRatio,
+		"max-consonant-run":           &h.MaxConsonantRun,
+		"max-vowel-run":               &h.MaxVowelRun,
+		"long-word-length":            &h.LongWordLength,
+		"min-long-word-syllables":     &h.MinLongWordSyllables,
+		"max-uncommon-bigram-ratio":   &h.MaxUncommonBigramRatio,
+		"max-vowel-free-window-ratio": &h.MaxVowelFreeWindowRatio,
+		"max-non-letter-ratio":        &h.MaxNonLetterRatio,
+		"max-mixed-run":               &h.MaxMixedRun,
+	}
+}
+
+// SetHeuristic sets a single threshold from a "key=value" assignment, for
+// example "max-consonant-run=6".
+//
+// Args:
+// h: *structs.Heuristics - Thresholds to update.
+// assignment: string - Threshold assignment.
+//
+// Returns:
+// error - Error if the key is unknown, the value is invalid, or the
+// heuristics are disabled by the off preset.
+func SetHeuristic(h *structs.Heuristics, assignment string) error {
+	key, value, found := strings.Cut(assignment, "=")
+	if !found {
+		return fmt.Errorf("heuristic %q must be in the form key=value", assignment)
+	}
+
+	key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+	if h.Disabled {
+		return fmt.Errorf("cannot set heuristic %s with the %s preset; choose %s, %s, or %s instead", key, HeuristicsOff, HeuristicsLoose, HeuristicsDefault, HeuristicsStrict)
+	}
+	fields := heuristicFields(h)
+
+	switch field := fields[key].(type) {
+	case *float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("heuristic %s must be a non-negative number, got %q", key, value)
+		}
+		*field = parsed
+
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("heuristic %s must be a non-negative integer, got %q", key, value)
+		}
+		*field = parsed
+
+	default:
+		keys := make([]string, 0, len(fields))
+		for name := range fields {
+			keys = append(keys, name)
+		}
+		slices.Sort(keys)
+
+		return fmt.Errorf("unknown heuristic %q, expected one of %s", key, strings.Join(keys, ", "))
+	}
+
+	return nil
+}
+
+// LoadHeuristicsFile applies the "key=value" assignments of a heuristics
+// file, one per line. Blank lines and lines starting with "#" are ignored.
+//
+// Args:
+// h: *structs.Heuristics - Thresholds to update.
+// path: string - Heuristics file.
+//
+// Returns:
+// error - Error if the file cannot be read or holds an invalid assignment.
+func LoadHeuristicsFile(h *structs.Heuristics, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open heuristics file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if err := SetHeuristic(h, line); err != nil {
+			return fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read heuristics file: %w", err)
+	}
+
+	return nil
+}
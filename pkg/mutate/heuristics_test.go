@@ -0,0 +1,47 @@
+package mutate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetHeuristic(t *testing.T) {
+	h, err := HeuristicPreset(HeuristicsDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetHeuristic(&h, "max-consonant-run=6"); err != nil {
+		t.Fatalf("SetHeuristic: %v", err)
+	}
+	if h.MaxConsonantRun != 6 {
+		t.Fatalf("MaxConsonantRun = %d, want 6", h.MaxConsonantRun)
+	}
+
+	for _, bad := range []string{"max-consonant-run", "no-such-key=1", "min-vowel-ratio=-1", "max-vowel-run=x"} {
+		if err := SetHeuristic(&h, bad); err == nil {
+			t.Errorf("SetHeuristic(%q) accepted an invalid assignment", bad)
+		}
+	}
+}
+
+func TestSetHeuristicRejectsOffPreset(t *testing.T) {
+	h, err := HeuristicPreset(HeuristicsOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := SetHeuristic(&h, "max-consonant-run=6"); err == nil {
+		t.Fatal("override accepted with the off preset")
+	}
+
+	path := filepath.Join(t.TempDir(), "heuristics.conf")
+	if err := os.WriteFile(path, []byte("# comment\nmax-vowel-run=3\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadHeuristicsFile(&h, path); err == nil {
+		t.Fatal("heuristics file accepted with the off preset")
+	}
+}
@@ -0,0 +1,189 @@
+package mutate
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// ResolveInputs expands the configured input paths into an ordered list of
+// regular files. Glob patterns are expanded, directories are walked (only
+// their direct entries unless cfg.Recursive is set), and the include/exclude
+// patterns are applied to files discovered through directory walks. Files
+// named explicitly are always kept.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// []string - De-duplicated list of files to read, in discovery order.
+// error - Error if a path does not exist, a glob matches nothing, or a
+// pattern is malformed.
+func ResolveInputs(cfg *structs.Config) ([]string, error) {
+	for _, pattern := range append(append([]string{}, cfg.IncludeGlobs...), cfg.ExcludeGlobs...) {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+
+	var files []string
+	seen := make(map[string]struct{})
+
+	add := func(path string) {
+		clean := filepath.Clean(path)
+		if _, exists := seen[clean]; exists {
+			return
+		}
+		seen[clean] = struct{}{}
+		files = append(files, clean)
+	}
+
+	for _, input := range cfg.InputPaths {
+		matches, err := expandInputPath(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to stat input %q: %w", match, err)
+			}
+
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			walked, err := walkInputDir(cfg, match)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, path := range walked {
+				add(path)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// expandInputPath returns the paths named by a single input argument. An
+// argument that exists on disk is returned as-is; otherwise it is treated as
+// a glob pattern, which lets quoted patterns reach brainstorm unexpanded.
+//
+// Args:
+// input: string - Raw input argument.
+//
+// Returns:
+// []string - Matching paths.
+// error - Error if the input neither exists nor matches anything.
+func expandInputPath(input string) ([]string, error) {
+	if _, err := os.Stat(input); err == nil {
+		return []string{input}, nil
+	}
+
+	if !strings.ContainsAny(input, "*?[") {
+		return nil, fmt.Errorf("input %q does not exist", input)
+	}
+
+	matches, err := filepath.Glob(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %q: %w", input, err)
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("glob %q matched no files", input)
+	}
+
+	return matches, nil
+}
+
+// walkInputDir lists the regular files within a directory in lexical order,
+// descending into subdirectories only when cfg.Recursive is set. Symbolic
+// links to regular files are listed like the files themselves, as they are
+// when named on the command line; links to directories are not followed,
+// which keeps link cycles from looping the walk.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// root: string - Directory to walk.
+//
+// Returns:
+// []string - Files that pass the include/exclude patterns.
+// error - Error if the directory cannot be read or a link is broken.
+func walkInputDir(cfg *structs.Config, root string) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", path, err)
+		}
+
+		if d.IsDir() {
+			if path != root && !cfg.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %q: %w", path, err)
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+		} else if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if matchesInputFilters(cfg, d.Name()) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// matchesInputFilters reports whether a file name passes the configured
+// include and exclude patterns. With no include patterns every name is
+// included; any matching exclude pattern rejects the name.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// name: string - Base name of the file.
+//
+// Returns:
+// bool - True if the file should be read.
+func matchesInputFilters(cfg *structs.Config, name string) bool {
+	for _, pattern := range cfg.ExcludeGlobs {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return false
+		}
+	}
+
+	if len(cfg.IncludeGlobs) == 0 {
+		return true
+	}
+
+	for _, pattern := range cfg.IncludeGlobs {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,150 @@
+package mutate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// writeInputTree creates files relative to a new temporary directory and
+// returns the directory.
+func writeInputTree(t *testing.T, files ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(root, name)
+
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestResolveInputs(t *testing.T) {
+	root := writeInputTree(t,
+		"a.txt", "b.log", "draft-c.txt",
+		"sub/d.txt", "sub/e.log", "sub/deep/f.txt",
+	)
+
+	in := func(names ...string) []string {
+		paths := make([]string, len(names))
+		for i, name := range names {
+			paths[i] = filepath.Join(root, name)
+		}
+		return paths
+	}
+
+	cases := []struct {
+		name string
+		cfg  structs.Config
+		want []string
+	}{
+		{
+			name: "directory",
+			cfg:  structs.Config{InputPaths: in(".")},
+			want: in("a.txt", "b.log", "draft-c.txt"),
+		},
+		{
+			name: "recursive",
+			cfg:  structs.Config{InputPaths: in("."), Recursive: true},
+			want: in("a.txt", "b.log", "draft-c.txt", "sub/d.txt", "sub/deep/f.txt", "sub/e.log"),
+		},
+		{
+			name: "include and exclude",
+			cfg: structs.Config{
+				InputPaths:   in("."),
+				Recursive:    true,
+				IncludeGlobs: []string{"*.txt"},
+				ExcludeGlobs: []string{"draft-*"},
+			},
+			want: in("a.txt", "sub/d.txt", "sub/deep/f.txt"),
+		},
+		{
+			name: "explicit files bypass the filters",
+			cfg: structs.Config{
+				InputPaths:   in("b.log", "sub"),
+				IncludeGlobs: []string{"*.txt"},
+			},
+			want: in("b.log", "sub/d.txt"),
+		},
+		{
+			name: "glob",
+			cfg:  structs.Config{InputPaths: in("*.txt", "sub/*.log")},
+			want: in("a.txt", "draft-c.txt", "sub/e.log"),
+		},
+		{
+			name: "duplicates",
+			cfg:  structs.Config{InputPaths: in("a.txt", "*.txt", ".")},
+			want: in("a.txt", "draft-c.txt", "b.log"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ResolveInputs(&tc.cfg)
+			if err != nil {
+				t.Fatalf("ResolveInputs: %v", err)
+			}
+
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("ResolveInputs = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveInputsErrors(t *testing.T) {
+	root := writeInputTree(t, "a.txt")
+
+	for _, cfg := range []structs.Config{
+		{InputPaths: []string{filepath.Join(root, "missing.txt")}},
+		{InputPaths: []string{filepath.Join(root, "*.gz")}},
+		{InputPaths: []string{root}, IncludeGlobs: []string{"[a-"}},
+	} {
+		if _, err := ResolveInputs(&cfg); err == nil {
+			t.Errorf("ResolveInputs(%q, include %q) succeeded", cfg.InputPaths, cfg.IncludeGlobs)
+		}
+	}
+}
+
+func TestResolveInputsSymlinks(t *testing.T) {
+	target := writeInputTree(t, "linked.txt", "dir/inner.txt")
+	root := writeInputTree(t, "a.txt")
+
+	for name, to := range map[string]string{
+		"file.txt": filepath.Join(target, "linked.txt"),
+		"dir":      filepath.Join(target, "dir"),
+	} {
+		if err := os.Symlink(to, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks unsupported: %v", err)
+		}
+	}
+
+	got, err := ResolveInputs(&structs.Config{InputPaths: []string{root}, Recursive: true})
+	if err != nil {
+		t.Fatalf("ResolveInputs: %v", err)
+	}
+
+	// The file link is read; the directory link is not followed.
+	want := []string{filepath.Join(root, "a.txt"), filepath.Join(root, "file.txt")}
+	if !slices.Equal(got, want) {
+		t.Fatalf("ResolveInputs = %q, want %q", got, want)
+	}
+
+	if err := os.Symlink(filepath.Join(target, "gone.txt"), filepath.Join(root, "broken.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ResolveInputs(&structs.Config{InputPaths: []string{root}}); err == nil {
+		t.Fatal("broken link accepted")
+	}
+}
@@ -0,0 +1,309 @@
+package mutate
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// LanguageAuto selects the language profile separately for each line.
+const LanguageAuto = "auto"
+
+// LanguageDefault is the profile used when no language is configured.
+const LanguageDefault = "en"
+
+// languageProfile describes the letters of a language as seen by the word
+// heuristics.
+//
+// vowels lists the lowercase vowels, including accented forms and "y" where
+// it acts as a vowel. digraphs lists lowercase letter sequences that spell
+// a single sound; they are collapsed to their first letter before vowel and
+// consonant runs are measured, so Polish "szcz" counts as two consonants.
+// uncommonBigrams lists letter pairs that rarely occur in the language.
+// markers lists letters and sequences typical of the language, used by the
+// auto mode to pick a profile for a line. asciiVowels caches the ASCII
+// vowels in both cases for the hot path of isVowel.
+type languageProfile struct {
+	name            string
+	vowels          string
+	digraphs        []string
+	uncommonBigrams map[[2]rune]struct{}
+	markers         []string
+	asciiVowels     [utf8.RuneSelf]bool
+}
+
+// englishUncommonBigrams is the historic uncommon-bigram table, shared by
+// most profiles with language-specific removals.
+var englishUncommonBigrams = []string{
+	"qx", "xq", "qj", "jq", "vk", "kj", "zx", "xk",
+	"vv", "ww", "zz", "qq", "xx", "kk", "jj",
+	"gf", "fg", "vd", "dv", "qz", "zq", "hj", "jh",
+}
+
+// bigramSet builds an uncommon-bigram set from the English table, leaving
+// out pairs that are common in the language.
+//
+// Args:
+// common: ...string - Pairs to leave out.
+//
+// Returns:
+// map[[2]rune]struct{} - Uncommon bigram set.
+func bigramSet(common ...string) map[[2]rune]struct{} {
+	set := make(map[[2]rune]struct{}, len(englishUncommonBigrams))
+
+	for _, bigram := range englishUncommonBigrams {
+		if !slices.Contains(common, bigram) {
+			set[[2]rune{rune(bigram[0]), rune(bigram[1])}] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+// languageProfiles lists the built-in profiles. In auto mode, ties between
+// profiles are resolved in this order, so English comes first.
+var languageProfiles = []*languageProfile{
+	{
+		name:            "en",
+		vowels:          "aeiou",
+		uncommonBigrams: bigramSet(),
+	},
+	{
+		name:            "de",
+		vowels:          "aeiouyäöü",
+		digraphs:        []string{"sch", "ch", "ck", "ph"},
+		uncommonBigrams: bigramSet(),
+		markers:         []string{"ä", "ö", "ü", "ß", "sch"},
+	},
+	{
+		name:            "fr",
+		vowels:          "aeiouyàâæèéêëîïôœùûüÿ",
+		digraphs:        []string{"ch", "ph", "qu", "gn"},
+		uncommonBigrams: bigramSet(),
+		markers:         []string{"é", "è", "ê", "à", "â", "ç", "œ", "ù", "û", "ë", "ï", "î", "ô", "eau"},
+	},
+	{
+		name:            "es",
+		vowels:          "aeiouáéíóúü",
+		digraphs:        []string{"ch", "ll", "rr", "qu"},
+		uncommonBigrams: bigramSet(),
+		markers:         []string{"ñ", "á", "í", "ó", "ú", "¿", "¡"},
+	},
+	{
+		name:            "pt",
+		vowels:          "aeiouáâãàéêíóôõú",
+		digraphs:        []string{"ch", "lh", "nh", "rr", "ss", "qu"},
+		uncommonBigrams: bigramSet(),
+		markers:         []string{"ã", "õ", "ç", "â", "ê", "ô", "lh", "nh"},
+	},
+	{
+		name:            "it",
+		vowels:          "aeiouàèéìíîòóùú",
+		digraphs:        []string{"ch", "gh", "gl", "gn", "sc"},
+		uncommonBigrams: bigramSet("zz"),
+		markers:         []string{"à", "è", "ì", "ò", "ù", "zz", "gli"},
+	},
+	{
+		name:            "nl",
+		vowels:          "aeiouyáéëïóöü",
+		digraphs:        []string{"ij", "sch", "ch"},
+		uncommonBigrams: bigramSet("kk"),
+		markers:         []string{"ij", "aa", "uu", "ë", "ï"},
+	},
+	{
+		name:            "pl",
+		vowels:          "aeiouyąęó",
+		digraphs:        []string{"sz", "cz", "rz", "dz", "dź", "dż", "ch"},
+		uncommonBigrams: bigramSet(),
+		markers:         []string{"ą", "ę", "ł", "ń", "ś", "ź", "ż", "sz", "cz", "rz"},
+	},
+	{
+		name:            "cs",
+		vowels:          "aeiouyáéěíóúůý",
+		digraphs:        []string{"ch"},
+		uncommonBigrams: bigramSet("dv", "vd"),
+		markers:         []string{"ě", "č", "ř", "š", "ž", "ů", "ý", "ť", "ď", "ň"},
+	},
+	{
+		name:            "sv",
+		vowels:          "aeiouyåäö",
+		digraphs:        []string{"sj", "sk", "tj", "kj", "ck"},
+		uncommonBigrams: bigramSet("kj", "hj"),
+		markers:         []string{"å", "ä", "ö"},
+	},
+	{
+		name:            "da",
+		vowels:          "aeiouyæøå",
+		digraphs:        []string{"sj", "kj"},
+		uncommonBigrams: bigramSet("kj", "hj"),
+		markers:         []string{"æ", "ø", "å"},
+	},
+	{
+		name:            "no",
+		vowels:          "aeiouyæøå",
+		digraphs:        []string{"skj", "sj", "kj"},
+		uncommonBigrams: bigramSet("kj", "hj"),
+		markers:         []string{"æ", "ø", "å", "skj"},
+	},
+	{
+		name:            "fi",
+		vowels:          "aeiouyäö",
+		uncommonBigrams: bigramSet("kk"),
+		markers:         []string{"ä", "ö", "kk", "ää", "yy"},
+	},
+	{
+		name:            "tr",
+		vowels:          "aeıioöuü",
+		uncommonBigrams: bigramSet("kk"),
+		markers:         []string{"ı", "ğ", "ş", "ç", "ö", "ü"},
+	},
+}
+
+// init fills the ASCII vowel table of each built-in profile.
+func init() {
+	for _, profile := range languageProfiles {
+		for _, r := range profile.vowels {
+			if r < utf8.RuneSelf {
+				profile.asciiVowels[r] = true
+				profile.asciiVowels[unicode.ToUpper(r)] = true
+			}
+		}
+	}
+}
+
+// LanguageNames returns the names of the built-in language profiles.
+//
+// Returns:
+// []string - Profile names, with English first.
+func LanguageNames() []string {
+	names := make([]string, 0, len(languageProfiles))
+
+	for _, profile := range languageProfiles {
+		names = append(names, profile.name)
+	}
+
+	return names
+}
+
+// IsValidLanguage reports whether name is a built-in profile or "auto".
+//
+// Args:
+// name: string - Language name to check.
+//
+// Returns:
+// bool - True if the language is supported.
+func IsValidLanguage(name string) bool {
+	return name == LanguageAuto || findLanguageProfile(name) != nil
+}
+
+// findLanguageProfile returns the built-in profile with the given name.
+//
+// Args:
+// name: string - Profile name.
+//
+// Returns:
+// *languageProfile - Profile, or nil if there is none.
+func findLanguageProfile(name string) *languageProfile {
+	for _, profile := range languageProfiles {
+		if profile.name == name {
+			return profile
+		}
+	}
+
+	return nil
+}
+
+// languageProfileFor returns the profile to apply to a line. In auto mode
+// the profile whose markers occur most often in the line wins; lines
+// without markers use English.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// line: string - Input line.
+//
+// Returns:
+// *languageProfile - Profile for the line.
+func languageProfileFor(cfg *structs.Config, line string) *languageProfile {
+	if cfg.Language != LanguageAuto {
+		if profile := findLanguageProfile(cfg.Language); profile != nil {
+			return profile
+		}
+
+		return languageProfiles[0]
+	}
+
+	lower := strings.ToLower(line)
+	best, bestScore := languageProfiles[0], 0
+
+	for _, profile := range languageProfiles[1:] {
+		score := 0
+		for _, marker := range profile.markers {
+			score += strings.Count(lower, marker)
+		}
+
+		if score > bestScore {
+			best, bestScore = profile, score
+		}
+	}
+
+	return best
+}
+
+// isVowel returns whether a rune is a vowel in the profile.
+//
+// Args:
+// r: rune - Character to test.
+//
+// Returns:
+// bool - True if the rune is a vowel, false otherwise.
+func (p *languageProfile) isVowel(r rune) bool {
+	if r >= 0 && r < utf8.RuneSelf {
+		return p.asciiVowels[r]
+	}
+
+	return strings.ContainsRune(p.vowels, unicode.ToLower(r))
+}
+
+// collapseDigraphs lowercases a string and replaces each digraph of the
+// profile with its first letter. Profiles without digraphs return the
+// string unchanged.
+//
+// Args:
+// s: string - Input string.
+//
+// Returns:
+// string - String used for heuristic measurements.
+func (p *languageProfile) collapseDigraphs(s string) string {
+	if len(p.digraphs) == 0 {
+		return s
+	}
+
+	lower := strings.ToLower(s)
+
+	var b strings.Builder
+	b.Grow(len(lower))
+
+	for i := 0; i < len(lower); {
+		matched := false
+
+		for _, digraph := range p.digraphs {
+			if strings.HasPrefix(lower[i:], digraph) {
+				first := []rune(digraph)[0]
+				b.WriteRune(first)
+				i += len(digraph)
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			b.WriteByte(lower[i])
+			i++
+		}
+	}
+
+	return b.String()
+}
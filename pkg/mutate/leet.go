@@ -0,0 +1,214 @@
+package mutate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Substitution modes accepted by structs.Config.LeetMode.
+const (
+	LeetOff    = ""
+	LeetAll    = "all"
+	LeetSingle = "single"
+	LeetFirst  = "first"
+)
+
+// DefaultSubstitutionTable returns the built-in character substitution
+// table. Keys are lowercase; substitutions apply to either case.
+//
+// Returns:
+// map[rune][]string - Replacement strings for each substitutable letter.
+func DefaultSubstitutionTable() map[rune][]string {
+	return map[rune][]string{
+		'a': {"@", "4"},
+		'b': {"8"},
+		'e': {"3"},
+		'g': {"9"},
+		'i': {"1", "!"},
+		'l': {"1"},
+		'o': {"0"},
+		's': {"$", "5"},
+		't': {"7"},
+		'z': {"2"},
+	}
+}
+
+// LoadSubstitutionTable reads a substitution table in hashcat table format:
+// one "x=y" pair per line, where x is a single character and y its
+// replacement. A character may appear on several lines to give it several
+// replacements. Blank lines and lines starting with "#" are ignored.
+//
+// Args:
+// path (string): Path to the table file.
+//
+// Returns:
+// map[rune][]string: Replacement strings for each substitutable character.
+// error: Error if the file cannot be read or a line is malformed.
+func LoadSubstitutionTable(path string) (map[rune][]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open substitution table: %w", err)
+	}
+	defer file.Close()
+
+	table := make(map[rune][]string)
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, replacement, found := strings.Cut(line, "=")
+		if !found || utf8.RuneCountInString(key) != 1 || replacement == "" {
+			return nil, fmt.Errorf("invalid substitution table line %d: %q, expected x=y", lineNumber, line)
+		}
+
+		r, _ := utf8.DecodeRuneInString(key)
+		r = unicode.ToLower(r)
+		table[r] = append(table[r], replacement)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read substitution table: %w", err)
+	}
+
+	return table, nil
+}
+
+// expandSubstitutions appends substitution variants of each candidate
+// according to the configured mode. The original candidates are kept and
+// each candidate contributes at most cfg.LeetMaxVariants variants.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+// candidates ([]string): Candidates to expand.
+//
+// Returns:
+// []string: The candidates followed by their variants.
+func expandSubstitutions(cfg *structs.Config, candidates []string) []string {
+	if cfg.LeetMode == LeetOff || len(candidates) == 0 {
+		return candidates
+	}
+
+	table := cfg.LeetTable
+	if table == nil {
+		table = DefaultSubstitutionTable()
+	}
+
+	expanded := make([]string, 0, len(candidates)*2)
+
+	for _, candidate := range candidates {
+		expanded = append(expanded, candidate)
+		expanded = append(expanded, substitutionVariants(cfg, table, candidate)...)
+	}
+
+	return expanded
+}
+
+// substitutionVariants generates the substitution variants of a single
+// candidate. The original candidate is not included.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+// table (map[rune][]string): Substitution table.
+// candidate (string): Candidate to expand.
+//
+// Returns:
+// []string: Distinct variants, at most cfg.LeetMaxVariants of them.
+func substitutionVariants(cfg *structs.Config, table map[rune][]string, candidate string) []string {
+	runes := []rune(candidate)
+
+	var positions []int
+	for i, r := range runes {
+		if _, exists := table[unicode.ToLower(r)]; exists {
+			positions = append(positions, i)
+		}
+	}
+
+	if len(positions) == 0 {
+		return nil
+	}
+
+	if cfg.LeetMode == LeetFirst && cfg.LeetPositions > 0 && len(positions) > cfg.LeetPositions {
+		positions = positions[:cfg.LeetPositions]
+	}
+
+	limit := cfg.LeetMaxVariants
+	seen := map[string]struct{}{candidate: {}}
+	var variants []string
+
+	add := func(variant string) bool {
+		if _, exists := seen[variant]; !exists {
+			seen[variant] = struct{}{}
+			variants = append(variants, variant)
+		}
+
+		return limit <= 0 || len(variants) < limit
+	}
+
+	if cfg.LeetMode == LeetSingle {
+		for _, pos := range positions {
+			for _, replacement := range table[unicode.ToLower(runes[pos])] {
+				variant := string(runes[:pos]) + replacement + string(runes[pos+1:])
+				if !add(variant) {
+					return variants
+				}
+			}
+		}
+
+		return variants
+	}
+
+	// Enumerate every combination of substitutions over the selected
+	// positions with an odometer, where choice 0 keeps the original letter.
+	choices := make([]int, len(positions))
+	var builder strings.Builder
+
+	for {
+		carry := true
+		for i := len(choices) - 1; i >= 0 && carry; i-- {
+			choices[i]++
+			if choices[i] > len(table[unicode.ToLower(runes[positions[i]])]) {
+				choices[i] = 0
+			} else {
+				carry = false
+			}
+		}
+
+		if carry {
+			return variants
+		}
+
+		builder.Reset()
+		next := 0
+
+		for i, pos := range positions {
+			builder.WriteString(string(runes[next:pos]))
+
+			if choices[i] == 0 {
+				builder.WriteRune(runes[pos])
+			} else {
+				builder.WriteString(table[unicode.ToLower(runes[pos])][choices[i]-1])
+			}
+
+			next = pos + 1
+		}
+
+		builder.WriteString(string(runes[next:]))
+
+		if !add(builder.String()) {
+			return variants
+		}
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package mutate
+
+import "os"
+
+// mapFile reads a whole file into memory on platforms without mmap support.
+//
+// Args:
+// path: string - File to read.
+//
+// Returns:
+// []byte - File contents.
+// func() error - No-op release function.
+// error - Error if the file cannot be read.
+func mapFile(path string) ([]byte, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
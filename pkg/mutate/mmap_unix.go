@@ -0,0 +1,42 @@
+//go:build unix
+
+package mutate
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile memory-maps a file read-only.
+//
+// Args:
+// path: string - File to map.
+//
+// Returns:
+// []byte - Mapped file contents.
+// func() error - Function unmapping the file.
+// error - Error if the file cannot be opened or mapped.
+func mapFile(path string) ([]byte, func() error, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if info.Size() == 0 {
+		return nil, func() error { return nil }, nil
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to map %q: %w", path, err)
+	}
+
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
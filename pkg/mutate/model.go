@@ -0,0 +1,288 @@
+package mutate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Character model defaults and limits.
+const (
+	DefaultModelOrder    = 3
+	DefaultMinModelScore = -1.6
+	maxModelOrder        = 8
+
+	// modelSmoothing is the pseudo-count added to every n-gram. A small
+	// value keeps unseen n-grams, typical of gibberish, clearly improbable.
+	modelSmoothing = 0.1
+)
+
+// Boundary markers padding each token, so that the model learns which
+// characters start and end words. Tokens never contain them, since they
+// consist of letters only.
+const (
+	modelStart = '^'
+	modelEnd   = '$'
+)
+
+// modelFileMagic identifies serialised character model files.
+const modelFileMagic = "BSMODEL1"
+
+// TrainCharModel builds a character n-gram model from the letter tokens of
+// the given reference texts. Compressed texts are decompressed
+// transparently.
+//
+// Args:
+// inputs: []string - Reference text files.
+// order: int - Number of characters per n-gram.
+//
+// Returns:
+// *structs.CharModel - Trained model.
+// error - Error if the order is invalid or a text cannot be read.
+func TrainCharModel(inputs []string, order int) (*structs.CharModel, error) {
+	if order < 2 || order > maxModelOrder {
+		return nil, fmt.Errorf("model order must be between 2 and %d, got %d", maxModelOrder, order)
+	}
+
+	model := &structs.CharModel{Order: order, Counts: make(map[string]uint32)}
+	alphabet := map[rune]struct{}{modelEnd: {}}
+
+	err := forEachWordlistLine(inputs, func(line []byte) {
+		for _, token := range modelTokens(string(line)) {
+			for _, r := range token {
+				alphabet[r] = struct{}{}
+			}
+
+			forEachModelGram(order, token, func(gram string) {
+				if model.Counts[gram] < math.MaxUint32 {
+					model.Counts[gram]++
+				}
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(model.Counts) == 0 {
+		return nil, errors.New("the reference text contains no words")
+	}
+
+	model.Alphabet = len(alphabet)
+	buildModelContexts(model)
+
+	return model, nil
+}
+
+// WriteCharModel saves a model as the magic string, the order and alphabet
+// size, and its n-grams in sorted order with their counts.
+//
+// Args:
+// model: *structs.CharModel - Model to save.
+// path: string - Output file path.
+//
+// Returns:
+// error - Error if the file cannot be written.
+func WriteCharModel(model *structs.CharModel, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create model file: %w", err)
+	}
+
+	writer := bufio.NewWriterSize(file, 1<<20)
+
+	header := append([]byte(modelFileMagic), byte(model.Order))
+	header = binary.LittleEndian.AppendUint32(header, uint32(model.Alphabet))
+	header = binary.AppendUvarint(header, uint64(len(model.Counts)))
+	_, _ = writer.Write(header)
+
+	var entry []byte
+	for _, gram := range slices.Sorted(maps.Keys(model.Counts)) {
+		entry = binary.AppendUvarint(entry[:0], uint64(len(gram)))
+		entry = append(entry, gram...)
+		entry = binary.AppendUvarint(entry, uint64(model.Counts[gram]))
+		_, _ = writer.Write(entry)
+	}
+
+	err = writer.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write model file: %w", err)
+	}
+
+	return nil
+}
+
+// LoadCharModel reads a model written by WriteCharModel.
+//
+// Args:
+// path: string - Model file path.
+//
+// Returns:
+// *structs.CharModel - Loaded model.
+// error - Error if the file cannot be read or is not a valid model.
+func LoadCharModel(path string) (*structs.CharModel, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open model file: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 1<<20)
+
+	header := make([]byte, len(modelFileMagic)+5)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read model header: %w", err)
+	}
+
+	if string(header[:len(modelFileMagic)]) != modelFileMagic {
+		return nil, errors.New("not a brainstorm model file")
+	}
+
+	model := &structs.CharModel{
+		Order:    int(header[len(modelFileMagic)]),
+		Alphabet: int(binary.LittleEndian.Uint32(header[len(modelFileMagic)+1:])),
+	}
+
+	if model.Order < 2 || model.Order > maxModelOrder || model.Alphabet < 1 {
+		return nil, errors.New("corrupt model header")
+	}
+
+	entries, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model header: %w", err)
+	}
+
+	model.Counts = make(map[string]uint32, min(entries, 1<<24))
+
+	for i := uint64(0); i < entries; i++ {
+		length, err := binary.ReadUvarint(reader)
+		if err != nil || length > uint64(model.Order*utf8.UTFMax) {
+			return nil, errors.New("corrupt model entry")
+		}
+
+		gram := make([]byte, length)
+		if _, err := io.ReadFull(reader, gram); err != nil {
+			return nil, fmt.Errorf("failed to read model entry: %w", err)
+		}
+
+		count, err := binary.ReadUvarint(reader)
+		if err != nil || count > math.MaxUint32 {
+			return nil, errors.New("corrupt model entry")
+		}
+
+		model.Counts[string(gram)] = uint32(count)
+	}
+
+	buildModelContexts(model)
+
+	return model, nil
+}
+
+// buildModelContexts derives the context counts of a model from its n-gram
+// counts.
+//
+// Args:
+// model: *structs.CharModel - Model to update.
+func buildModelContexts(model *structs.CharModel) {
+	model.Contexts = make(map[string]uint64, len(model.Counts)/4)
+
+	for gram, count := range model.Counts {
+		_, size := utf8.DecodeLastRuneInString(gram)
+		model.Contexts[gram[:len(gram)-size]] += uint64(count)
+	}
+}
+
+// modelTokens splits text into lowercase runs of letters.
+//
+// Args:
+// text: string - Input text.
+//
+// Returns:
+// []string - Letter tokens.
+func modelTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// forEachModelGram calls fn for every n-gram of a token padded with
+// boundary markers.
+//
+// Args:
+// order: int - Number of characters per n-gram.
+// token: string - Letter token.
+// fn: func(string) - Callback receiving each n-gram.
+func forEachModelGram(order int, token string, fn func(gram string)) {
+	padded := []rune(strings.Repeat(string(modelStart), order-1) + token + string(modelEnd))
+
+	for i := order - 1; i < len(padded); i++ {
+		fn(string(padded[i-order+1 : i+1]))
+	}
+}
+
+// scoreModelToken returns the mean log10 probability per character of a
+// token under the model, with add-k smoothing.
+//
+// Args:
+// model: *structs.CharModel - Character model.
+// token: string - Letter token.
+//
+// Returns:
+// float64 - Mean log10 probability; closer to zero is more word-like.
+func scoreModelToken(model *structs.CharModel, token string) float64 {
+	var (
+		total float64
+		grams int
+	)
+
+	forEachModelGram(model.Order, token, func(gram string) {
+		_, size := utf8.DecodeLastRuneInString(gram)
+		context := model.Contexts[gram[:len(gram)-size]]
+
+		total += math.Log10((float64(model.Counts[gram]) + modelSmoothing) / (float64(context) + modelSmoothing*float64(model.Alphabet)))
+		grams++
+	})
+
+	return total / float64(grams)
+}
+
+// modelRejection scores each letter token of a line and rejects the line
+// when any token scores below the minimum, so a single gibberish word is
+// enough to drop an otherwise word-like line.
+//
+// Args:
+// model: *structs.CharModel - Character model.
+// minScore: float64 - Minimum mean log10 probability per character of each token.
+// line: string - Input line.
+//
+// Returns:
+// rejection - Rejection carrying the lowest token score and the minimum.
+// bool - True if a token scored below the minimum.
+func modelRejection(model *structs.CharModel, minScore float64, line string) (rejection, bool) {
+	lowest := math.Inf(1)
+
+	for _, token := range modelTokens(line) {
+		lowest = min(lowest, scoreModelToken(model, token))
+	}
+
+	if lowest < minScore {
+		return rejection{reason: RejectModelScoreLow, value: lowest, limit: minScore}, true
+	}
+
+	return rejection{}, false
+}
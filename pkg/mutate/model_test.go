@@ -0,0 +1,50 @@
+package mutate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModelRejectionPerToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reference.txt")
+	reference := strings.Repeat("the quick brown fox jumps over the lazy dog\nthere is another house near the river\n", 50)
+
+	if err := os.WriteFile(path, []byte(reference), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := TrainCharModel([]string{path}, DefaultModelOrder)
+	if err != nil {
+		t.Fatalf("TrainCharModel: %v", err)
+	}
+
+	good := scoreModelToken(model, "the")
+	bad := scoreModelToken(model, "xqzvkj")
+	if good <= bad {
+		t.Fatalf("word score %f not above gibberish score %f", good, bad)
+	}
+
+	minScore := (good + bad) / 2
+
+	if why, rejected := modelRejection(model, minScore, "the quick brown fox"); rejected {
+		t.Fatalf("word-like line rejected: %v", why)
+	}
+
+	// A line of good words with one gibberish token must be rejected even
+	// when the mean over its tokens would pass.
+	line := "the the the the the the the the xqzvkj"
+	why, rejected := modelRejection(model, minScore, line)
+	if !rejected {
+		t.Fatal("line with a gibberish token passed")
+	}
+
+	if why.reason != RejectModelScoreLow || why.value != bad {
+		t.Fatalf("rejection %+v, want reason %s with value %f", why, RejectModelScoreLow, bad)
+	}
+
+	if _, rejected := modelRejection(model, minScore, "1234 !!"); rejected {
+		t.Fatal("line without letters rejected by the model")
+	}
+}
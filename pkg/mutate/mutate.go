@@ -2,8 +2,11 @@
 package mutate
 
 import (
-	"bufio"
+	"bytes"
+	"slices"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/hashcracky/brainstorm/pkg/structs"
 
@@ -13,6 +16,12 @@ import (
 
 // TransformLine applies the core brainstorm transformation to a single input line.
 //
+// In rules mode (cfg.RulesOut set), only base candidates are produced: each
+// n-gram keeps its original case, or takes the title style when that style
+// is requested, with words separated by spaces, no
+// substitution or affix variants are added, and only the maximum output
+// length is enforced, since the rules may lengthen a candidate.
+//
 // Args:
 // cfg: *structs.Config - Application configuration.
 // line: []byte - Raw input line (without trailing newline).
@@ -20,46 +29,140 @@ import (
 // Returns:
 // []byte - Transformed line (without trailing newline).
 func TransformLine(cfg *structs.Config, line []byte) []byte {
-	line = removeTrailingNonLettersDigits(line)
-	line = removeLeadingNonLettersDigits(line)
-	line = filterLines(cfg, line)
+	return transformLine(cfg, line, nil)
+}
+
+// transformLine implements TransformLine, reporting rejected lines and
+// candidates, and optionally the output of each stage, to the trace.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// line: []byte - Raw input line (without trailing newline).
+// trace: *transformTrace - Optional trace; nil disables tracing.
+//
+// Returns:
+// []byte - Transformed line (without trailing newline).
+func transformLine(cfg *structs.Config, line []byte, trace *transformTrace) []byte {
+	return appendLines(nil, transformCandidates(cfg, line, trace))
+}
+
+// transformCandidates runs every stage of the transformation over the lines
+// of data and returns the resulting candidates. The stages pass slices of
+// tokens to each other, so no stage re-splits or re-joins its input.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// data: []byte - Raw input line; embedded newlines separate lines.
+// trace: *transformTrace - Optional trace; nil disables tracing.
+//
+// Returns:
+// []string - Candidates, in output order.
+func transformCandidates(cfg *structs.Config, data []byte, trace *transformTrace) []string {
+	if bytes.IndexByte(data, '\n') < 0 {
+		return transformSingleLine(cfg, data, trace)
+	}
+
+	var candidates []string
+	for line := range bytes.SplitSeq(data, []byte{'\n'}) {
+		candidates = append(candidates, transformSingleLine(cfg, line, trace)...)
+	}
+
+	return candidates
+}
+
+// transformSingleLine runs every stage of the transformation over a single
+// line without newlines.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// raw: []byte - Raw input line.
+// trace: *transformTrace - Optional trace; nil disables tracing.
+//
+// Returns:
+// []string - Candidates, in output order.
+func transformSingleLine(cfg *structs.Config, raw []byte, trace *transformTrace) []string {
+	line := trimNonLetters(raw)
+	trace.stage(stageTrim, line)
 
 	if len(line) == 0 {
+		if len(bytes.TrimSpace(raw)) > 0 {
+			trace.rejected(string(raw), rejection{reason: RejectNoLetters})
+		}
+
+		trace.stage(stageHeuristics, nil)
+
 		return nil
 	}
 
-	processedChunk := generateNGramSliceBytes(line, cfg.NGramMin, cfg.NGramMax)
-	processedChunk = []byte(strings.Join(prepareStringForTransformations(processedChunk), "\n"))
+	text := string(line)
 
-	processedChunk = []byte(strings.Join(applyPostFilters(processedChunk), "\n"))
+	if why, rejected := lineRejection(cfg, text); rejected {
+		trace.rejected(text, why)
+		trace.stage(stageHeuristics, nil)
 
-	return enforceLengthRange(processedChunk, cfg.OutMinLength, cfg.OutMaxLength)
+		return nil
+	}
+
+	trace.stage(stageHeuristics, line)
+
+	rulesMode := cfg.RulesOut != ""
+
+	styles, separators := cfg.CaseStyles, cfg.Separators
+	if rulesMode {
+		styles, separators = rulesBaseStyles(styles), []string{" "}
+	}
+
+	nGrams := generateNGrams(text, cfg.NGramMin, cfg.NGramMax)
+	trace.stageList(stageNGrams, nGrams)
+
+	variants := prepareStringForTransformations(nGrams, styles, separators)
+	trace.stageList(stageStyles, variants)
+
+	candidates := applyPostFilters(variants, trace)
+	trace.stageList(stagePostFilters, candidates)
+
+	if rulesMode {
+		candidates = enforceLengthRange(candidates, 1, cfg.OutMaxLength, trace)
+		trace.stageList(stageLength, candidates)
+
+		return candidates
+	}
+
+	candidates = expandSubstitutions(cfg, candidates)
+	trace.stageList(stageSubstitutions, candidates)
+
+	candidates = expandAffixes(cfg, candidates)
+	trace.stageList(stageAffixes, candidates)
+
+	candidates = enforceLengthRange(candidates, cfg.OutMinLength, cfg.OutMaxLength, trace)
+	trace.stageList(stageLength, candidates)
+
+	return candidates
 }
 
-// generateNGramSliceBytes takes a byte slice and generates a new byte slice
-// using the GenerateNGramsBytes function and combines the results.
+// appendLines appends lines to dst separated by newlines, without a
+// trailing newline.
 //
 // Args:
-// input ([]byte): The original byte slice to generate n-grams from
-// wordRangeStart (int): The starting number of words to use for n-grams
-// wordRangeEnd (int): The ending iteration number of words to use for n-grams
+// dst: []byte - Buffer to append to.
+// lines: []string - Lines to append.
 //
 // Returns:
-// []byte: A new byte slice with the n-grams generated.
-func generateNGramSliceBytes(input []byte, wordRangeStart int, wordRangeEnd int) []byte {
-	data := string(input)
-	lines := strings.Split(data, "\n")
-	var newList []string
+// []byte - Extended buffer.
+func appendLines(dst []byte, lines []string) []byte {
+	for i, line := range lines {
+		if i > 0 {
+			dst = append(dst, '\n')
+		}
 
-	for _, line := range lines {
-		nGrams := generateNGrams(line, wordRangeStart, wordRangeEnd)
-		newList = append(newList, nGrams...)
+		dst = append(dst, line...)
 	}
 
-	return []byte(strings.Join(newList, "\n"))
+	return dst
 }
 
-// generateNGrams generates n-grams from a string of text and returns a slice of n-grams.
+// generateNGrams generates n-grams from a string of text and returns a slice
+// of n-grams. N-grams left empty once punctuation is removed are dropped.
 //
 // Args:
 // text (string): The text to generate n-grams from.
@@ -70,7 +173,16 @@ func generateNGramSliceBytes(input []byte, wordRangeStart int, wordRangeEnd int)
 // []string: A slice of n-grams.
 func generateNGrams(text string, wordRangeStart int, wordRangeEnd int) []string {
 	words := strings.Fields(text)
-	var nGrams []string
+	for i, word := range words {
+		if strings.ContainsAny(word, ".,;") {
+			words[i] = nGramPunctuation.Replace(word)
+		}
+	}
+
+	var (
+		nGrams  []string
+		builder strings.Builder
+	)
 
 	for i := wordRangeStart; i <= wordRangeEnd; i++ {
 		if i <= 0 || i > len(words) {
@@ -78,111 +190,251 @@ func generateNGrams(text string, wordRangeStart int, wordRangeEnd int) []string
 		}
 
 		for j := 0; j <= len(words)-i; j++ {
-			nGram := strings.Join(words[j:j+i], " ")
-			nGram = strings.TrimSpace(nGram)
-			nGram = strings.TrimLeft(nGram, " ")
-			nGram = strings.ReplaceAll(nGram, ".", "")
-			nGram = strings.ReplaceAll(nGram, ",", "")
-			nGram = strings.ReplaceAll(nGram, ";", "")
-			nGrams = append(nGrams, nGram)
+			builder.Reset()
+
+			for k, word := range words[j : j+i] {
+				if k > 0 {
+					builder.WriteByte(' ')
+				}
+				builder.WriteString(word)
+			}
+
+			if builder.Len() > 0 {
+				nGrams = append(nGrams, builder.String())
+			}
 		}
 	}
 
 	return nGrams
 }
 
-// prepareStringForTransformations processes each line in the input byte slice,
-// removes unwanted characters, normalizes each line, and generates various
-// transformed versions for each line.
+// nGramPunctuation removes the punctuation dropped from n-gram words.
+var nGramPunctuation = strings.NewReplacer(".", "", ",", "", ";", "")
+
+// Case styles accepted by structs.Config.CaseStyles.
+const (
+	StyleLower    = "lower"
+	StyleUpper    = "upper"
+	StyleTitle    = "title"
+	StyleCamel    = "camel"
+	StyleSentence = "sentence"
+	StyleOriginal = "original"
+)
+
+// IsValidCaseStyle reports whether name is a supported case style.
 //
 // Args:
-// data ([]byte): The byte slice containing lines to process.
+// name (string): The style name to check.
+//
+// Returns:
+// bool: True if the style is supported.
+func IsValidCaseStyle(name string) bool {
+	switch name {
+	case StyleLower, StyleUpper, StyleTitle, StyleCamel, StyleSentence, StyleOriginal:
+		return true
+	}
+
+	return false
+}
+
+// prepareStringForTransformations cleans each n-gram, removing control
+// characters, and generates one variant per combination of case style and
+// word separator. Identical variants of the same n-gram are emitted once.
+//
+// With no styles or separators given, the title style joined without a
+// separator is used ("hello world" -> "HelloWorld"), and n-grams without a
+// space are kept as-is. An n-gram whose second word was punctuation only,
+// such as "check ", still contains a space and is title-cased ("Check").
+//
+// Args:
+// nGrams ([]string): The n-grams to process.
+// styles ([]string): Case styles to apply (see the Style constants).
+// separators ([]string): Strings used to join the words of each line.
 //
 // Returns:
 // []string: A flattened slice of all prepared string variants for all lines.
-func prepareStringForTransformations(data []byte) []string {
-	input := string(data)
-	scanner := bufio.NewScanner(strings.NewReader(input))
+func prepareStringForTransformations(nGrams []string, styles []string, separators []string) []string {
+	if len(styles) == 0 {
+		styles = []string{StyleTitle}
+	}
 
-	var results []string
+	if len(separators) == 0 {
+		separators = []string{""}
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	results := make([]string, 0, len(nGrams)*len(styles)*len(separators))
 
-		clean := strings.ReplaceAll(line, "\x00", "")
-		clean = strings.ReplaceAll(clean, "\n", "")
-		clean = strings.ReplaceAll(clean, "\t", "")
-		clean = strings.ReplaceAll(clean, "\r", "")
-		clean = strings.ReplaceAll(clean, "\f", "")
-		clean = strings.ReplaceAll(clean, "\v", "")
+	for _, line := range nGrams {
+		clean := line
+		if strings.ContainsAny(clean, "\x00\n\t\r\f\v") {
+			clean = controlCharacters.Replace(clean)
+		}
 
 		if strings.TrimSpace(clean) == "" {
 			continue
 		}
 
-		if strings.Contains(clean, " ") {
-			results = append(
-				results,
-				strings.ReplaceAll(
-					cases.Title(language.Und, cases.NoLower).String(clean),
-					" ",
-					"",
-				),
-			)
-		} else {
-			results = append(results, strings.ReplaceAll(clean, " ", ""))
+		first := len(results)
+
+		for _, style := range styles {
+			words := applyCaseStyle(clean, style)
+
+			for _, sep := range separators {
+				variant := strings.Join(words, sep)
+
+				if slices.Contains(results[first:], variant) {
+					continue
+				}
+
+				results = append(results, variant)
+			}
 		}
 	}
 
 	return results
 }
 
-// applyPostFilters applies post-processing filters on the transformed output
-// lines, including removing unbalanced leading-quote or leading-bracket
-// variants and adding apostrophe-stripped variants.
+// controlCharacters removes the control characters stripped from n-grams.
+var controlCharacters = strings.NewReplacer("\x00", "", "\n", "", "\t", "", "\r", "", "\f", "", "\v", "")
+
+// Title casers are stateful, so each goroutine takes its own from a pool.
+var (
+	titleCasers = sync.Pool{New: func() any {
+		caser := cases.Title(language.Und)
+		return &caser
+	}}
+	titleNoLowerCasers = sync.Pool{New: func() any {
+		caser := cases.Title(language.Und, cases.NoLower)
+		return &caser
+	}}
+)
+
+// titleString applies a pooled title caser to a string.
 //
 // Args:
-// data ([]byte): The byte slice containing transformed lines.
+// pool (*sync.Pool): Pool of *cases.Caser to use.
+// s (string): The string to transform.
 //
 // Returns:
-// []string: A slice of filtered and augmented lines.
-func applyPostFilters(data []byte) []string {
-	input := string(data)
-	scanner := bufio.NewScanner(strings.NewReader(input))
+// string: The title-cased string.
+func titleString(pool *sync.Pool, s string) string {
+	caser := pool.Get().(*cases.Caser)
+	defer pool.Put(caser)
 
-	var filtered []string
+	return caser.String(s)
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+// applyCaseStyle splits a space-separated n-gram into words and applies a
+// case style to them.
+//
+// Args:
+// s (string): The n-gram to transform.
+// style (string): The case style to apply.
+//
+// Returns:
+// []string: The transformed words, ready to be joined.
+func applyCaseStyle(s string, style string) []string {
+	words := strings.FieldsFunc(s, func(r rune) bool { return r == ' ' })
+
+	switch style {
+	case StyleLower:
+		for i, w := range words {
+			words[i] = strings.ToLower(w)
+		}
 
-		if line == "" {
-			continue
+	case StyleUpper:
+		for i, w := range words {
+			words[i] = strings.ToUpper(w)
+		}
+
+	case StyleTitle:
+		if strings.Contains(s, " ") {
+			words = strings.FieldsFunc(
+				titleString(&titleNoLowerCasers, s),
+				func(r rune) bool { return r == ' ' },
+			)
 		}
 
-		if hasUnbalancedLeadingDelimiter(line) {
+	case StyleCamel:
+		for i, w := range words {
+			if i == 0 {
+				words[i] = strings.ToLower(w)
+			} else {
+				words[i] = titleString(&titleCasers, w)
+			}
+		}
+
+	case StyleSentence:
+		for i, w := range words {
+			words[i] = strings.ToLower(w)
+		}
+		if len(words) > 0 {
+			words[0] = titleString(&titleCasers, words[0])
+		}
+	}
+
+	return words
+}
+
+// applyPostFilters applies post-processing filters on the transformed output
+// lines, including removing unbalanced leading-quote or leading-bracket
+// variants and adding apostrophe-stripped variants. Removed lines, and
+// lines replaced by their apostrophe-stripped variant, are reported to the
+// trace.
+//
+// Args:
+// lines ([]string): The transformed lines.
+// trace (*transformTrace): Optional trace receiving rejected lines.
+//
+// Returns:
+// []string: A slice of filtered and augmented lines.
+func applyPostFilters(lines []string, trace *transformTrace) []string {
+	filtered := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		if line == "" {
 			continue
 		}
 
-		if hasUnbalancedDelimitersAnywhere(line) {
+		if hasUnbalancedLeadingDelimiter(line) || hasUnbalancedDelimitersAnywhere(line) {
+			trace.rejected(line, rejection{reason: RejectUnbalancedDelimiter})
 			continue
 		}
 
 		// If middle-quote noise is present, emit apostrophe-free variants so there is still output.
+		// The line is only rejected when it has no such variant.
 		if containsMiddleQuoteNoise(line) {
-			apostropheFreeVariants := generateApostropheFreeVariants(line)
-			filtered = append(filtered, apostropheFreeVariants...)
+			kept := len(filtered)
+			filtered = appendApostropheFreeVariant(filtered, line)
+
+			if len(filtered) > kept {
+				trace.replaced(line, filtered[kept], RejectQuoteNoise)
+			} else {
+				trace.rejected(line, rejection{reason: RejectQuoteNoise})
+			}
+
 			continue
 		}
 
 		filtered = append(filtered, line)
-
-		apostropheFreeVariants := generateApostropheFreeVariants(line)
-		filtered = append(filtered, apostropheFreeVariants...)
+		filtered = appendApostropheFreeVariant(filtered, line)
 	}
 
 	return filtered
 }
 
+// leadingDelimiterClosers maps each opening quote or bracket to the
+// characters that close it.
+var leadingDelimiterClosers = map[rune][]rune{
+	'(': {')'},
+	'[': {']'},
+	'{': {'}'},
+	'<': {'>'},
+	'"': {'"', '”'},
+	'“': {'”', '"'},
+	'‘': {'’', '\''},
+}
+
 // hasUnbalancedLeadingDelimiter checks whether a string starts with an opening
 // quote or bracket and lacks the corresponding closing quote or bracket later
 // in the token.
@@ -197,26 +449,14 @@ func hasUnbalancedLeadingDelimiter(s string) bool {
 		return false
 	}
 
-	runes := []rune(s)
-
-	openToClose := map[rune][]rune{
-		'(': {')'},
-		'[': {']'},
-		'{': {'}'},
-		'<': {'>'},
-		'"': {'"', '”'},
-		'“': {'”', '"'},
-		'‘': {'’', '\''},
-	}
+	first, size := utf8.DecodeRuneInString(s)
 
-	first := runes[0]
-
-	allowedClosers, isOpening := openToClose[first]
+	allowedClosers, isOpening := leadingDelimiterClosers[first]
 	if !isOpening {
 		return false
 	}
 
-	for _, r := range runes[1:] {
+	for _, r := range s[size:] {
 		for _, c := range allowedClosers {
 			if r == c {
 				return false
@@ -227,19 +467,19 @@ func hasUnbalancedLeadingDelimiter(s string) bool {
 	return true
 }
 
-// generateApostropheFreeVariants returns variants of the input string where
-// apostrophes are removed. The original string is not included in the
-// returned slice.
+// appendApostropheFreeVariant appends the variant of the input string with
+// apostrophes removed, unless it has no apostrophes or the variant would be
+// empty.
 //
 // Args:
-// s (string): The string to generate variants from.
+// dst ([]string): The slice to append to.
+// s (string): The string to generate the variant from.
 //
 // Returns:
-// []string: A slice of apostrophe-free variants, or an empty slice if no
-// apostrophes are present.
-func generateApostropheFreeVariants(s string) []string {
+// []string: The extended slice.
+func appendApostropheFreeVariant(dst []string, s string) []string {
 	if !strings.ContainsAny(s, "'’") {
-		return nil
+		return dst
 	}
 
 	variant := strings.Map(
@@ -254,8 +494,8 @@ func generateApostropheFreeVariants(s string) []string {
 	)
 
 	if variant == "" || variant == s {
-		return nil
+		return dst
 	}
 
-	return []string{variant}
+	return append(dst, variant)
 }
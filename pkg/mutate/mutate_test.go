@@ -0,0 +1,75 @@
+package mutate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// TestDefaultOutputMatchesBaseline pins the output of the default styles
+// and separators to that of the original implementation, captured in
+// testdata/default_baseline.txt by running it over default_corpus.txt with
+// the default -w 1-5 and -l 4-32.
+func TestDefaultOutputMatchesBaseline(t *testing.T) {
+	corpus, err := os.ReadFile("testdata/default_corpus.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("testdata/default_baseline.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &structs.Config{
+		NGramMin:     1,
+		NGramMax:     5,
+		OutMinLength: 4,
+		OutMaxLength: 32,
+		Ordered:      true,
+		OrderWindow:  1024,
+	}
+
+	var got bytes.Buffer
+	if _, err := NewPipeline(cfg).Run(context.Background(), bytes.NewReader(corpus), &got); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got.String() != string(want) {
+		gotLines := strings.Split(got.String(), "\n")
+		wantLines := strings.Split(string(want), "\n")
+
+		for i := range min(len(gotLines), len(wantLines)) {
+			if gotLines[i] != wantLines[i] {
+				t.Fatalf("line %d: got %q, want %q", i+1, gotLines[i], wantLines[i])
+			}
+		}
+
+		t.Fatalf("got %d lines, want %d", len(gotLines), len(wantLines))
+	}
+}
+
+func TestApplyCaseStyleTitle(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"hello world", []string{"Hello", "World"}},
+		{"iPhone rocks", []string{"IPhone", "Rocks"}},
+		{"hello", []string{"hello"}},
+		// Punctuation-only words leave a trailing space; the baseline still
+		// title-cased such n-grams.
+		{"check ", []string{"Check"}},
+	}
+
+	for _, tc := range cases {
+		if got := applyCaseStyle(tc.in, StyleTitle); !slices.Equal(got, tc.want) {
+			t.Errorf("applyCaseStyle(%q, title) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
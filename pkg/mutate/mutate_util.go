@@ -2,20 +2,22 @@ package mutate
 
 import (
 	"bufio"
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
-	"io"
 	"os"
-	"runtime"
 	"strings"
-	"sync"
 	"unicode"
 
 	"github.com/hashcracky/brainstorm/pkg/structs"
 )
 
-// ProcessStream reads from stdin, processes lines concurrently without preserving
-// order, and writes results to stdout as soon as they are available.
+// ProcessStream reads the configured input files, or stdin when no input
+// paths are given, processes lines concurrently without preserving order, and
+// writes results to stdout as soon as they are available. In explain mode
+// (cfg.Explain set), it prints the decision trace for that input instead.
+// When resuming, stdout is first cut back to the size recorded by the
+// checkpoint.
 //
 // Args:
 // cfg: *structs.Config - Application configuration.
@@ -23,150 +25,166 @@ import (
 // Returns:
 // error - Any error encountered during processing.
 func ProcessStream(cfg *structs.Config) error {
-	stat, err := os.Stdin.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat stdin: %w", err)
-	}
-
-	if (stat.Mode() & os.ModeCharDevice) != 0 {
-		return fmt.Errorf("no stdin detected; supply input via a pipe or redirection")
-	}
+	return ProcessStreamContext(context.Background(), cfg)
+}
 
-	reader := bufio.NewReaderSize(os.Stdin, 1<<20)
-	writer := bufio.NewWriterSize(os.Stdout, 1<<20)
+// ProcessStreamContext behaves like ProcessStream but stops reading input
+// when ctx is cancelled. Lines already read are still processed, the output
+// is flushed, and the context error is returned. A failed write to stdout
+// also stops the run and is returned; it wraps syscall.EPIPE when stdout is
+// a pipe whose reader has exited, provided SIGPIPE is ignored or notified.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// error - Any error encountered during processing, or the context error if
+// the run was cancelled.
+func ProcessStreamContext(ctx context.Context, cfg *structs.Config) error {
+	if cfg.Explain != "" {
+		for _, line := range Explain(cfg, cfg.Explain) {
+			fmt.Println(line)
+		}
 
-	var writeMu sync.Mutex
+		return nil
+	}
 
-	defer func() {
-		writeMu.Lock()
-		_ = writer.Flush()
-		writeMu.Unlock()
-	}()
+	pipeline := NewPipeline(cfg)
 
-	type lineTask struct {
-		Data []byte
+	if cfg.RulesOut != "" {
+		if err := writeRuleFile(cfg); err != nil {
+			return err
+		}
 	}
 
-	taskCh := make(chan lineTask, 1024)
+	if cfg.Resume {
+		if err := prepareResumeOutput(cfg.Checkpoint, os.Stdout); err != nil {
+			return err
+		}
+	}
 
-	workerCount := runtime.NumCPU()
-	var wg sync.WaitGroup
+	if len(cfg.InputPaths) > 0 {
+		resolved, err := ResolveInputs(cfg)
+		if err != nil {
+			return err
+		}
 
-	for i := 0; i < workerCount; i++ {
-		wg.Add(1)
+		if len(resolved) == 0 {
+			return fmt.Errorf("no input files matched the supplied paths")
+		}
 
-		go func() {
-			defer wg.Done()
+		stats, err := pipeline.RunFiles(ctx, resolved, os.Stdout)
 
-			for task := range taskCh {
-				lineCopy := make([]byte, len(task.Data))
-				copy(lineCopy, task.Data)
+		return reportRun(cfg, stats, err)
+	}
 
-				processed := TransformLine(cfg, lineCopy)
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat stdin: %w", err)
+	}
 
-				if len(processed) == 0 {
-					continue
-				}
+	if (stat.Mode() & os.ModeCharDevice) != 0 {
+		return fmt.Errorf("no stdin detected; supply input files or input via a pipe or redirection")
+	}
 
-				writeMu.Lock()
+	stats, err := pipeline.Run(ctx, os.Stdin, os.Stdout)
 
-				_, werr := writer.Write(processed)
-				if werr == nil {
-					_, werr = writer.Write([]byte{'\n'})
-				}
+	return reportRun(cfg, stats, err)
+}
 
-				writeMu.Unlock()
+// reportRun prints the run summary to stderr and, when configured, writes
+// the statistics file. The summary is printed even when the run failed.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// stats: structs.Stats - Statistics for the run.
+// runErr: error - Error returned by the run.
+//
+// Returns:
+// error - The run error, or an error writing the statistics file.
+func reportRun(cfg *structs.Config, stats structs.Stats, runErr error) error {
+	reportStats(stats)
 
-				if werr != nil {
-					return
-				}
-			}
-		}()
+	if cfg.StatsJSON != "" {
+		if err := writeStatsJSON(stats, cfg.StatsJSON); err != nil && runErr == nil {
+			return err
+		}
 	}
 
-	for {
-		rawLine, readErr := reader.ReadBytes('\n')
-
-		if len(rawLine) > 0 {
-			hasNewline := rawLine[len(rawLine)-1] == '\n'
-
-			var raw []byte
+	return runErr
+}
 
-			if hasNewline {
-				raw = rawLine[:len(rawLine)-1]
-			} else {
-				raw = rawLine
-			}
+// writeRuleFile writes the companion rule file for rules mode.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// error - Error if the rules are invalid or the file cannot be written.
+func writeRuleFile(cfg *structs.Config) error {
+	rules, err := GenerateRules(cfg)
+	if err != nil {
+		return err
+	}
 
-			taskCh <- lineTask{
-				Data: raw,
-			}
-		}
+	file, err := os.Create(cfg.RulesOut)
+	if err != nil {
+		return fmt.Errorf("failed to create rule file: %w", err)
+	}
 
-		if readErr != nil {
-			if errors.Is(readErr, io.EOF) {
-				break
-			}
+	writer := bufio.NewWriter(file)
 
-			close(taskCh)
-			wg.Wait()
+	for _, rule := range rules {
+		_, _ = writer.WriteString(rule + "\n")
+	}
 
-			return fmt.Errorf("error reading from stdin: %w", readErr)
-		}
+	err = writer.Flush()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
 
-	close(taskCh)
-	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("failed to write rule file %q: %w", cfg.RulesOut, err)
+	}
 
 	return nil
 }
 
-// filterLines checks each line and skips those that consist only of digits or
-// special characters and those that are unlikely to contain words. In unicode
-// mode (cfg.IncludeNonLatin), lines containing any non-Latin letters are accepted
-// without Latin vowel heuristics.
+// lineRejection applies the line-level word heuristics, skipping lines that
+// consist only of digits or special characters and those that are unlikely
+// to contain words. The line is measured with the configured language
+// profile, followed by the character model when one is configured. In
+// unicode mode (cfg.IncludeNonLatin), lines containing any non-Latin letters
+// are accepted without the word heuristics.
 //
 // Args:
 // cfg (*structs.Config): Configuration.
-// data ([]byte): The byte slice containing the data to be processed.
+// line (string): Input line.
 //
 // Returns:
-// []byte: The processed byte slice with filtered lines.
-func filterLines(cfg *structs.Config, data []byte) []byte {
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
-	var result strings.Builder
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
+// rejection: Reason the line was rejected.
+// bool: True if the line was rejected.
+func lineRejection(cfg *structs.Config, line string) (rejection, bool) {
+	if isAllDigitsOrSpecialChars(line) {
+		return rejection{reason: RejectNoLetters}, true
+	}
 
-		if isAllDigitsOrSpecialChars(line) {
-			continue
-		}
+	h := heuristicsFor(cfg)
 
-		if cfg.IncludeNonLatin {
-			if containsNonLatinLetter(line) {
-				result.WriteString(line + "\n")
-				continue
-			}
-			if !likelyContainsWords(line) {
-				continue
-			}
-			result.WriteString(line + "\n")
-			continue
-		}
+	if !h.Disabled && !(cfg.IncludeNonLatin && containsNonLatinLetter(line)) {
+		profile := languageProfileFor(cfg, line)
 
-		if !likelyContainsWords(line) {
-			continue
+		if why, rejected := wordRejection(h, profile, profile.collapseDigraphs(line)); rejected {
+			return why, true
 		}
+	}
 
-		result.WriteString(line + "\n")
+	if cfg.Model != nil {
+		return modelRejection(cfg.Model, cfg.MinModelScore, line)
 	}
 
-	return []byte(result.String())
+	return rejection{}, false
 }
 
 // containsNonLatinLetter returns true if the string contains at least one
@@ -208,54 +226,62 @@ func isAllDigitsOrSpecialChars(s string) bool {
 	return !hasLetter
 }
 
-// likelyContainsWords checks a string to see if there are atleast 5 characters
+// wordRejection checks a string to see if there are atleast 5 characters
 // in a row that are not digits or special characters and ensures that there is
 // atleast one vowel in the string.
 //
 // Args:
+// h (structs.Heuristics): Word-likeness thresholds.
+// p (*languageProfile): Language profile.
 // s (string): The string to check.
 //
 // Returns:
-// bool: True if the string likely contains words, false otherwise.
-func likelyContainsWords(s string) bool {
+// rejection: Reason the string is unlikely to contain words.
+// bool: True if the string was rejected.
+func wordRejection(h structs.Heuristics, p *languageProfile, s string) (rejection, bool) {
 	if len(s) < 2 {
-		return false
+		return rejection{reason: RejectTooShort, value: float64(len(s)), limit: 2}, true
 	}
 
 	if len(s) < 5 {
-		return looksLikeWordPattern(s)
+		return wordPatternRejection(h, p, s)
 	}
 
-	if !looksLikeWordPattern(s) {
-		return false
+	if why, rejected := wordPatternRejection(h, p, s); rejected {
+		return why, true
 	}
 
 	vowelCount := 0
+	runes := []rune(s)
 
-	for i := 0; i < len(s)-4; i++ {
-		if isWordLike(s[i : i+5]) {
+	for i := 0; i < len(runes)-4; i++ {
+		if isWordLike(p, runes[i:i+5]) {
 			vowelCount++
 		}
 	}
 
-	return vowelCount > 0
+	if vowelCount == 0 {
+		return rejection{reason: RejectNoWordWindow}, true
+	}
+
+	return rejection{}, false
 }
 
-// isWordLike checks if a substring contains at least one vowel and no more
+// isWordLike checks if a window contains at least one vowel and no more
 // than one digit or special character.
 //
 // Args:
-// s (string): The substring to check.
+// p (*languageProfile): Language profile.
+// window ([]rune): The window to check.
 //
 // Returns:
-// bool: True if the substring is likely a word, false otherwise.
-func isWordLike(s string) bool {
-	vowels := "aeiouAEIOU"
+// bool: True if the window is likely a word, false otherwise.
+func isWordLike(p *languageProfile, window []rune) bool {
 	digitOrSpecialCount := 0
 	hasVowel := false
 
-	for _, char := range s {
-		if strings.ContainsRune(vowels, char) {
+	for _, char := range window {
+		if p.isVowel(char) {
 			hasVowel = true
 		} else if !unicode.IsLetter(char) {
 			digitOrSpecialCount++
@@ -265,90 +291,55 @@ func isWordLike(s string) bool {
 	return hasVowel && digitOrSpecialCount <= 1
 }
 
-// removeTrailingNonLettersDigits removes trailing characters from each line
-// that are not letters.
-//
-// Args:
-// data ([]byte): The byte slice containing the data to be processed.
-//
-// Returns:
-// []byte: The processed byte slice with trailing non-letter characters removed.
-func removeTrailingNonLettersDigits(data []byte) []byte {
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
-	var result strings.Builder
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		processedLine := strings.TrimRightFunc(line, func(r rune) bool {
-			return !unicode.IsLetter(r)
-		})
-
-		result.WriteString(processedLine + "\n")
-	}
-
-	return []byte(result.String())
-}
-
-// removeLeadingNonLettersDigits removes leading characters from each line
-// that are not letters.
+// trimNonLetters removes leading and trailing characters that are not
+// letters.
 //
 // Args:
-// data ([]byte): The byte slice containing the data to be processed.
+// line ([]byte): The line to trim.
 //
 // Returns:
-// []byte: The processed byte slice with leading non-letter characters removed.
-func removeLeadingNonLettersDigits(data []byte) []byte {
-	scanner := bufio.NewScanner(strings.NewReader(string(data)))
-	var result strings.Builder
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		processedLine := strings.TrimLeftFunc(line, func(r rune) bool {
-			return !unicode.IsLetter(r)
-		})
-
-		result.WriteString(processedLine + "\n")
-	}
-
-	return []byte(result.String())
+// []byte: The trimmed line, sharing memory with the input.
+func trimNonLetters(line []byte) []byte {
+	return bytes.TrimFunc(line, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
 }
 
-// enforceLengthRange filters the input byte slice to only include strings
-// between minLength and maxLength characters inclusive.
+// enforceLengthRange filters the candidates to only include strings between
+// minLength and maxLength bytes inclusive. Dropped strings are reported to
+// the trace.
 //
 // Args:
-// input ([]byte): The input byte slice to filter.
+// candidates ([]string): The candidates to filter.
 // minLength (int): The minimum length of strings to include.
 // maxLength (int): The maximum length of strings to include.
+// trace (*transformTrace): Optional trace receiving rejected strings.
 //
 // Returns:
-// []byte: A new byte slice with strings within the specified length range.
-func enforceLengthRange(input []byte, minLength int, maxLength int) []byte {
-	lines := strings.Split(string(input), "\n")
-	var filtered []string
+// []string: The candidates within the specified length range, reusing the
+// input slice.
+func enforceLengthRange(candidates []string, minLength int, maxLength int, trace *transformTrace) []string {
+	filtered := candidates[:0]
 
-	for _, line := range lines {
+	for _, line := range candidates {
 		if len(line) >= minLength && len(line) <= maxLength {
 			filtered = append(filtered, line)
+			continue
 		}
-	}
 
-	return []byte(strings.Join(filtered, "\n"))
-}
+		if line == "" {
+			continue
+		}
 
-// isVowel returns whether a rune is a vowel.
-//
-// Args:
-// r: rune - Character to test.
-//
-// Returns:
-// bool - True if the rune is a vowel, false otherwise.
-func isVowel(r rune) bool {
-	vowels := "aeiouAEIOU"
+		limit := minLength
+		if len(line) > maxLength {
+			limit = maxLength
+		}
+
+		trace.rejected(line, rejection{reason: RejectLengthOutOfRange, value: float64(len(line)), limit: float64(limit)})
+	}
 
-	return strings.ContainsRune(vowels, r)
+	return filtered
 }
 
 // isLetterLike returns whether a rune should be treated as a word letter.
@@ -362,39 +353,42 @@ func isLetterLike(r rune) bool {
 	return unicode.IsLetter(r) || r == '\''
 }
 
-// looksLikeWordPattern applies heuristic checks for vowel density and
+// wordPatternRejection applies heuristic checks for vowel density and
 // consonant run length to decide whether a string looks like a word.
 //
 // Args:
+// h: structs.Heuristics - Word-likeness thresholds.
+// p: *languageProfile - Language profile.
 // s: string - Input string.
 //
 // Returns:
-// bool - True if the string passes heuristic word checks, false otherwise.
-func looksLikeWordPattern(s string) bool {
+// rejection - Reason the string does not look like a word.
+// bool - True if the string failed a heuristic word check.
+func wordPatternRejection(h structs.Heuristics, p *languageProfile, s string) (rejection, bool) {
 	if len(s) == 0 {
-		return false
+		return rejection{reason: RejectTooShort, limit: 2}, true
 	}
 
-	if hasSuspiciousAlphaNumericRuns(s) {
-		return false
+	if run := longestMixedRun(s); run > h.MaxMixedRun {
+		return rejection{reason: RejectAlphaNumericRun, value: float64(run), limit: float64(h.MaxMixedRun)}, true
 	}
 
-	if hasHighNonLetterDensity(s) {
-		return false
+	if density := nonLetterDensity(s); density > h.MaxNonLetterRatio {
+		return rejection{reason: RejectNonLetterDensity, value: density, limit: h.MaxNonLetterRatio}, true
 	}
 
-	if containsTooManyUncommonClusters(s) {
-		return false
+	if why, rejected := uncommonClusterRejection(h, p, s); rejected {
+		return why, true
 	}
 
-	syllables := countSyllableLikeSegments(s)
+	syllables := countSyllableLikeSegments(p, s)
 
 	if syllables == 0 {
-		return false
+		return rejection{reason: RejectNoSyllables, limit: 1}, true
 	}
 
-	if len(s) >= 8 && syllables < 2 {
-		return false
+	if len(s) >= h.LongWordLength && syllables < h.MinLongWordSyllables {
+		return rejection{reason: RejectFewSyllables, value: float64(syllables), limit: float64(h.MinLongWordSyllables)}, true
 	}
 
 	var (
@@ -413,7 +407,7 @@ func looksLikeWordPattern(s string) bool {
 
 		letterCount++
 
-		if isVowel(r) {
+		if p.isVowel(r) {
 			vowelCount++
 			currentVowelRun++
 			if currentVowelRun > maxVowelRun {
@@ -430,46 +424,44 @@ func looksLikeWordPattern(s string) bool {
 	}
 
 	if letterCount == 0 {
-		return false
+		return rejection{reason: RejectNoLetters}, true
 	}
 
 	vowelRatio := float64(vowelCount) / float64(letterCount)
 
-	if vowelRatio < 0.25 {
-		return false
+	if vowelRatio < h.MinVowelRatio {
+		return rejection{reason: RejectVowelRatioLow, value: vowelRatio, limit: h.MinVowelRatio}, true
 	}
 
-	if vowelRatio > 0.8 {
-		return false
+	if vowelRatio > h.MaxVowelRatio {
+		return rejection{reason: RejectVowelRatioHigh, value: vowelRatio, limit: h.MaxVowelRatio}, true
 	}
 
-	if maxConsonantRun > 4 {
-		return false
+	if maxConsonantRun > h.MaxConsonantRun {
+		return rejection{reason: RejectConsonantRun, value: float64(maxConsonantRun), limit: float64(h.MaxConsonantRun)}, true
 	}
 
-	if maxVowelRun > 3 {
-		return false
+	if maxVowelRun > h.MaxVowelRun {
+		return rejection{reason: RejectVowelRun, value: float64(maxVowelRun), limit: float64(h.MaxVowelRun)}, true
 	}
 
-	return true
+	return rejection{}, false
 }
 
-// containsTooManyUncommonClusters checks for a high ratio of uncommon
-// letter clusters that are unlikely in natural language.
+// uncommonClusterRejection checks for a high ratio of letter pairs that are
+// uncommon in the profile's language, and of five-letter windows without a
+// vowel.
 //
 // Args:
+// h: structs.Heuristics - Word-likeness thresholds.
+// p: *languageProfile - Language profile.
 // s: string - Input string.
 //
 // Returns:
+// rejection - Reason the string contains too many uncommon clusters.
 // bool - True if the string contains too many uncommon clusters.
-func containsTooManyUncommonClusters(s string) bool {
-	uncommonBigrams := map[string]struct{}{
-		"qx": {}, "xq": {}, "qj": {}, "jq": {}, "vk": {}, "kj": {}, "zx": {}, "xk": {},
-		"vv": {}, "ww": {}, "zz": {}, "qq": {}, "xx": {}, "kk": {}, "jj": {},
-		"gf": {}, "fg": {}, "vd": {}, "dv": {}, "qz": {}, "zq": {}, "hj": {}, "jh": {},
-	}
-
-	lower := strings.ToLower(s)
+func uncommonClusterRejection(h structs.Heuristics, p *languageProfile, s string) (rejection, bool) {
+	lower := []rune(strings.ToLower(s))
 
 	var (
 		totalBigrams      int
@@ -481,55 +473,50 @@ func containsTooManyUncommonClusters(s string) bool {
 		a := lower[i]
 		b := lower[i+1]
 
-		if !unicode.IsLetter(rune(a)) || !unicode.IsLetter(rune(b)) {
+		if !unicode.IsLetter(a) || !unicode.IsLetter(b) {
 			continue
 		}
 
 		totalBigrams++
 
-		key := string([]byte{a, b})
-
-		if _, exists := uncommonBigrams[key]; exists {
+		if _, exists := p.uncommonBigrams[[2]rune{a, b}]; exists {
 			uncommonBigramCnt++
 		}
 
-		if i+4 < len(lower) {
-			window := lower[i : i+5]
-
-			if !windowHasVowel(window) {
-				noVowelWindowCnt++
-			}
+		if i+4 < len(lower) && !windowHasVowel(p, lower[i:i+5]) {
+			noVowelWindowCnt++
 		}
 	}
 
 	if totalBigrams == 0 {
-		return false
+		return rejection{}, false
 	}
 
 	uncommonRatio := float64(uncommonBigramCnt) / float64(totalBigrams)
 	noVowelRatio := float64(noVowelWindowCnt) / float64(totalBigrams)
 
-	if uncommonRatio > 0.2 {
-		return true
+	if uncommonRatio > h.MaxUncommonBigramRatio {
+		return rejection{reason: RejectUncommonBigrams, value: uncommonRatio, limit: h.MaxUncommonBigramRatio}, true
 	}
 
-	if noVowelRatio > 0.35 {
-		return true
+	if noVowelRatio > h.MaxVowelFreeWindowRatio {
+		return rejection{reason: RejectVowelFreeWindows, value: noVowelRatio, limit: h.MaxVowelFreeWindowRatio}, true
 	}
 
-	return false
+	return rejection{}, false
 }
 
-// windowHasVowel checks whether a byte window contains at least one vowel.
+// windowHasVowel checks whether a window contains at least one vowel.
 //
 // Args:
-// window: string - Input window.
+// p: *languageProfile - Language profile.
+// window: []rune - Input window.
 //
 // Returns:
 // bool - True if a vowel is present, false otherwise.
-func windowHasVowel(window string) bool {
+func windowHasVowel(p *languageProfile, window []rune) bool {
 	for _, r := range window {
-		if isVowel(r) {
+		if p.isVowel(r) {
 			return true
 		}
 	}
@@ -541,12 +528,13 @@ func windowHasVowel(window string) bool {
 // consonant-plus-vowel patterns.
 //
 // Args:
+// p: *languageProfile - Language profile.
 // s: string - Input string.
 //
 // Returns:
 // int - Approximated number of syllable-like segments.
-func countSyllableLikeSegments(s string) int {
-	lower := strings.ToLower(s)
+func countSyllableLikeSegments(p *languageProfile, s string) int {
+	lower := []rune(strings.ToLower(s))
 	var (
 		syllables int
 		i         int
@@ -554,18 +542,18 @@ func countSyllableLikeSegments(s string) int {
 	)
 
 	for i < n {
-		for i < n && !isLetterLike(rune(lower[i])) {
+		for i < n && !isLetterLike(lower[i]) {
 			i++
 		}
 
-		for i < n && isLetterLike(rune(lower[i])) && !isVowel(rune(lower[i])) {
+		for i < n && isLetterLike(lower[i]) && !p.isVowel(lower[i]) {
 			i++
 		}
 
-		if i < n && isVowel(rune(lower[i])) {
+		if i < n && p.isVowel(lower[i]) {
 			syllables++
 
-			for i < n && isVowel(rune(lower[i])) {
+			for i < n && p.isVowel(lower[i]) {
 				i++
 			}
 		}
@@ -574,15 +562,15 @@ func countSyllableLikeSegments(s string) int {
 	return syllables
 }
 
-// hasHighNonLetterDensity checks whether a string contains a high ratio
-// of digits and special characters compared to letters.
+// nonLetterDensity returns the ratio of digits and special characters to
+// letters plus digits and special characters.
 //
 // Args:
 // s: string - Input string.
 //
 // Returns:
-// bool - True if non-letter density is too high, false otherwise.
-func hasHighNonLetterDensity(s string) bool {
+// float64 - Non-letter ratio, or 1 if the string has no letters.
+func nonLetterDensity(s string) float64 {
 	var letterCount int
 	var nonLetterCount int
 
@@ -595,34 +583,24 @@ func hasHighNonLetterDensity(s string) bool {
 	}
 
 	if letterCount == 0 {
-		return true
-	}
-
-	total := letterCount + nonLetterCount
-
-	if total == 0 {
-		return false
-	}
-
-	nonLetterRatio := float64(nonLetterCount) / float64(total)
-
-	if nonLetterRatio > 0.3 {
-		return true
+		return 1
 	}
 
-	return false
+	return float64(nonLetterCount) / float64(letterCount+nonLetterCount)
 }
 
-// hasSuspiciousAlphaNumericRuns checks for long sequences where digits
-// are embedded inside otherwise alphabetic chunks.
+// longestMixedRun returns the length of the longest run of letters and
+// digits that contains both, which usually indicates identifiers or hashes
+// rather than words.
 //
 // Args:
 // s: string - Input string.
 //
 // Returns:
-// bool - True if suspicious alphanumeric runs are present.
-func hasSuspiciousAlphaNumericRuns(s string) bool {
+// int - Length of the longest mixed letter-and-digit run, or 0 if none.
+func longestMixedRun(s string) int {
 	var (
+		longest       int
 		currentRunLen int
 		hasLetter     bool
 		hasDigit      bool
@@ -646,15 +624,15 @@ func hasSuspiciousAlphaNumericRuns(s string) bool {
 				hasDigit = true
 			}
 
-			if currentRunLen >= 6 && hasLetter && hasDigit {
-				return true
+			if hasLetter && hasDigit && currentRunLen > longest {
+				longest = currentRunLen
 			}
 		} else {
 			reset()
 		}
 	}
 
-	return false
+	return longest
 }
 
 // hasUnbalancedDelimitersAnywhere reports whether parentheses/brackets/braces
@@ -0,0 +1,124 @@
+package mutate
+
+import (
+	"context"
+	"sync"
+)
+
+// reorderBuffer restores input order between the workers and the output
+// sink. Every batch read holds one slot of a fixed-size window until its
+// results have been passed to the sink, so at most window batches are in
+// flight or waiting and memory stays bounded regardless of worker skew.
+//
+// Because results reach the sink strictly in input order, the buffer also
+// knows the input position up to which every line has been passed on, which
+// checkpoints record.
+type reorderBuffer struct {
+	mu        sync.Mutex
+	next      uint64
+	pending   map[uint64]reorderEntry
+	sink      outputSink
+	slots     chan struct{}
+	committed inputPosition
+}
+
+// reorderEntry holds the results of a completed batch waiting for its turn.
+type reorderEntry struct {
+	chunk *outputChunk
+	end   inputPosition
+}
+
+// newReorderBuffer creates a reorder buffer feeding the given sink.
+//
+// Args:
+// sink: outputSink - Sink receiving results in input order.
+// window: int - Maximum number of batches in flight.
+// start: inputPosition - Input position the run starts at.
+//
+// Returns:
+// *reorderBuffer - Empty reorder buffer starting at sequence zero.
+func newReorderBuffer(sink outputSink, window int, start inputPosition) *reorderBuffer {
+	if window < 1 {
+		window = 1
+	}
+
+	return &reorderBuffer{
+		pending:   make(map[uint64]reorderEntry),
+		sink:      sink,
+		slots:     make(chan struct{}, window),
+		committed: start,
+	}
+}
+
+// Acquire reserves a window slot for the next batch read, blocking while the
+// window is full.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+//
+// Returns:
+// error - Context error if ctx is cancelled while waiting.
+func (b *reorderBuffer) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Complete records the results for a sequence number and passes every
+// result that is now contiguous with the output to the sink, releasing
+// their window slots and output chunks. Batches that produced no candidates
+// must still be completed with an empty chunk so that later batches are not
+// held back.
+//
+// Args:
+// seq: uint64 - Sequence number of the completed batch.
+// end: inputPosition - Input position just past the batch.
+// chunk: *outputChunk - Candidates of the batch, possibly empty.
+//
+// Returns:
+// error - Error returned by the sink.
+func (b *reorderBuffer) Complete(seq uint64, end inputPosition, chunk *outputChunk) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending[seq] = reorderEntry{chunk: chunk, end: end}
+
+	for {
+		ready, exists := b.pending[b.next]
+		if !exists {
+			return nil
+		}
+
+		delete(b.pending, b.next)
+		b.next++
+		<-b.slots
+
+		if len(ready.chunk.data) > 0 {
+			if err := b.sink.Emit(ready.chunk.data); err != nil {
+				return err
+			}
+		}
+
+		releaseOutputChunk(ready.chunk)
+
+		b.committed = ready.end
+	}
+}
+
+// Committed calls fn with the input position up to which every line has
+// been passed to the sink. No results are passed on while fn runs.
+//
+// Args:
+// fn: func(inputPosition) error - Callback receiving the position.
+//
+// Returns:
+// error - Error returned by fn.
+func (b *reorderBuffer) Committed(fn func(position inputPosition) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return fn(b.committed)
+}
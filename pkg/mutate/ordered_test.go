@@ -0,0 +1,134 @@
+package mutate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink collects emitted candidates in the order they arrive.
+type recordingSink struct {
+	mu      sync.Mutex
+	emitted []string
+	err     error
+}
+
+func (s *recordingSink) Emit(candidates []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.emitted = append(s.emitted, string(candidates))
+
+	return s.err
+}
+
+func (s *recordingSink) Close() error {
+	return nil
+}
+
+func chunkOf(data string) *outputChunk {
+	chunk := newOutputChunk()
+	chunk.data = append(chunk.data, data...)
+
+	return chunk
+}
+
+func TestReorderBufferRestoresOrder(t *testing.T) {
+	sink := &recordingSink{}
+	buffer := newReorderBuffer(sink, 4, inputPosition{})
+
+	for range 4 {
+		if err := buffer.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	complete := func(seq uint64, data string) {
+		t.Helper()
+		if err := buffer.Complete(seq, inputPosition{Offset: int64(seq+1) * 10}, chunkOf(data)); err != nil {
+			t.Fatalf("Complete(%d): %v", seq, err)
+		}
+	}
+
+	committed := func() inputPosition {
+		var position inputPosition
+		_ = buffer.Committed(func(p inputPosition) error {
+			position = p
+			return nil
+		})
+		return position
+	}
+
+	complete(2, "c")
+	complete(1, "b")
+
+	if len(sink.emitted) != 0 {
+		t.Fatalf("emitted %q before batch 0 completed", sink.emitted)
+	}
+	if committed() != (inputPosition{}) {
+		t.Fatalf("committed %+v before batch 0 completed", committed())
+	}
+
+	complete(0, "a")
+	// An empty batch must not hold back later batches.
+	complete(3, "")
+
+	if got := strings.Join(sink.emitted, ","); got != "a,b,c" {
+		t.Fatalf("emitted %q, want a,b,c", got)
+	}
+	if committed() != (inputPosition{Offset: 40}) {
+		t.Fatalf("committed %+v, want offset 40", committed())
+	}
+}
+
+func TestReorderBufferWindowBlocks(t *testing.T) {
+	buffer := newReorderBuffer(&recordingSink{}, 1, inputPosition{})
+
+	if err := buffer.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := buffer.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second Acquire on a full window returned %v", err)
+	}
+
+	if err := buffer.Complete(0, inputPosition{}, chunkOf("x")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := buffer.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire after Complete: %v", err)
+	}
+}
+
+func TestReorderBufferStartPosition(t *testing.T) {
+	start := inputPosition{Source: 2, Offset: 99}
+	buffer := newReorderBuffer(&recordingSink{}, 1, start)
+
+	err := buffer.Committed(func(position inputPosition) error {
+		if position != start {
+			t.Fatalf("committed %+v, want %+v", position, start)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReorderBufferSinkError(t *testing.T) {
+	sink := &recordingSink{err: errors.New("disk full")}
+	buffer := newReorderBuffer(sink, 1, inputPosition{})
+
+	_ = buffer.Acquire(context.Background())
+
+	if err := buffer.Complete(0, inputPosition{}, chunkOf("x")); err == nil {
+		t.Fatal("sink error not returned")
+	}
+}
@@ -0,0 +1,325 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Output formats accepted by structs.Config.CountFormat.
+const (
+	CountFormatTSV   = "tsv"
+	CountFormatPlain = "plain"
+)
+
+// errStopMerge ends a spillCounter merge early without reporting an error.
+var errStopMerge = errors.New("stop merge")
+
+// outputSink consumes the candidates produced by the workers.
+type outputSink interface {
+	// Emit receives the newline-delimited candidates produced for one batch
+	// of input lines. It is called concurrently by the workers and must not
+	// retain candidates after returning.
+	Emit(candidates []byte) error
+
+	// Close writes any pending output and releases resources. It is called
+	// once, after every worker has exited.
+	Close() error
+}
+
+// newOutputSink builds the sink selected by the pipeline configuration.
+//
+// Args:
+// w: io.Writer - Output stream.
+// counters: *runCounters - Counters updated as candidates are written.
+//
+// Returns:
+// outputSink - Sink writing to w.
+// error - Error if the configuration is invalid.
+func (p *Pipeline) newOutputSink(w io.Writer, counters *runCounters) (outputSink, error) {
+	writer := bufio.NewWriterSize(w, bufferSize(p.cfg))
+	memLimit := int64(p.cfg.SortMemoryMB) << 20
+
+	if p.cfg.Count {
+		if p.cfg.CountFormat != CountFormatTSV && p.cfg.CountFormat != CountFormatPlain {
+			return nil, fmt.Errorf("unknown count format %q", p.cfg.CountFormat)
+		}
+
+		return &countSink{
+			writer:   writer,
+			counter:  newSpillCounter(memLimit, p.cfg.TempDir),
+			cfg:      p.cfg,
+			counters: counters,
+		}, nil
+	}
+
+	if p.cfg.Dedup == DedupDisk {
+		return &sortedUniqueSink{
+			writer:   writer,
+			counter:  newSpillCounter(memLimit, p.cfg.TempDir),
+			counters: counters,
+		}, nil
+	}
+
+	dedup, err := newDeduplicator(p.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamSink{
+		writer:   writer,
+		dedup:    dedup,
+		counters: counters,
+	}, nil
+}
+
+// streamSink writes candidates as soon as they are emitted, optionally
+// dropping candidates that were already written.
+type streamSink struct {
+	mu       sync.Mutex
+	writer   *bufio.Writer
+	dedup    deduplicator
+	counters *runCounters
+}
+
+// Emit writes the candidates that pass the deduplicator.
+//
+// Args:
+// candidates: []byte - Newline-delimited candidates.
+//
+// Returns:
+// error - Error if writing fails.
+func (s *streamSink) Emit(candidates []byte) error {
+	if s.dedup == nil {
+		s.mu.Lock()
+
+		_, err := s.writer.Write(candidates)
+		if err == nil {
+			err = s.writer.WriteByte('\n')
+		}
+
+		s.mu.Unlock()
+
+		if err != nil {
+			return err
+		}
+
+		for candidate := range bytes.SplitSeq(candidates, []byte{'\n'}) {
+			s.counters.recordOutput(candidate)
+		}
+
+		return nil
+	}
+
+	var (
+		kept    []byte
+		dropped int64
+	)
+
+	for candidate := range bytes.SplitSeq(candidates, []byte{'\n'}) {
+		if !s.dedup.Add(candidate) {
+			dropped++
+			continue
+		}
+
+		kept = append(kept, candidate...)
+		kept = append(kept, '\n')
+	}
+
+	s.counters.duplicates.Add(dropped)
+
+	if len(kept) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	_, err := s.writer.Write(kept)
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	for candidate := range bytes.SplitSeq(kept[:len(kept)-1], []byte{'\n'}) {
+		s.counters.recordOutput(candidate)
+	}
+
+	return nil
+}
+
+// Flush writes buffered output to the underlying writer.
+//
+// Returns:
+// error - Error if flushing fails.
+func (s *streamSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writer.Flush()
+}
+
+// Close flushes buffered output.
+//
+// Returns:
+// error - Error if flushing fails.
+func (s *streamSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.writer.Flush()
+}
+
+// sortedUniqueSink collects every candidate into a disk-backed table and
+// writes each distinct candidate once, in byte order, when closed.
+type sortedUniqueSink struct {
+	writer   *bufio.Writer
+	counter  *spillCounter
+	counters *runCounters
+}
+
+// Emit records the candidates for the final merge.
+//
+// Args:
+// candidates: []byte - Newline-delimited candidates.
+//
+// Returns:
+// error - Error if spilling to disk fails.
+func (s *sortedUniqueSink) Emit(candidates []byte) error {
+	for candidate := range bytes.SplitSeq(candidates, []byte{'\n'}) {
+		if err := s.counter.Add(candidate, 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close merges the recorded candidates, writes each distinct one, and
+// removes the temporary files.
+//
+// Returns:
+// error - Error if merging or writing fails.
+func (s *sortedUniqueSink) Close() error {
+	defer func() { _ = s.counter.Close() }()
+
+	err := s.counter.Merge(func(key []byte, count int64) error {
+		s.counters.duplicates.Add(count - 1)
+		s.counters.recordOutput(key)
+
+		if _, err := s.writer.Write(key); err != nil {
+			return err
+		}
+
+		return s.writer.WriteByte('\n')
+	})
+	if err != nil {
+		return fmt.Errorf("failed to merge deduplicated output: %w", err)
+	}
+
+	return s.writer.Flush()
+}
+
+// countSink tallies how often each candidate is produced and, when closed,
+// writes candidates by descending frequency. Ties are broken by byte order
+// so that output is reproducible.
+type countSink struct {
+	writer   *bufio.Writer
+	counter  *spillCounter
+	cfg      *structs.Config
+	counters *runCounters
+}
+
+// Emit records one occurrence of each candidate.
+//
+// Args:
+// candidates: []byte - Newline-delimited candidates.
+//
+// Returns:
+// error - Error if spilling to disk fails.
+func (s *countSink) Emit(candidates []byte) error {
+	for candidate := range bytes.SplitSeq(candidates, []byte{'\n'}) {
+		if err := s.counter.Add(candidate, 1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close ranks the tallied candidates, applies the minimum-count and top-N
+// cutoffs, writes the result, and removes the temporary files.
+//
+// The ranking reuses spillCounter: each (candidate, count) pair is re-keyed
+// as the inverted count in big-endian form followed by the candidate, so the
+// byte order of the new keys is descending count, then ascending candidate.
+//
+// Returns:
+// error - Error if merging or writing fails.
+func (s *countSink) Close() error {
+	defer func() { _ = s.counter.Close() }()
+
+	ranked := newSpillCounter(int64(s.cfg.SortMemoryMB)<<20, s.cfg.TempDir)
+	defer func() { _ = ranked.Close() }()
+
+	var rankKey []byte
+
+	err := s.counter.Merge(func(key []byte, count int64) error {
+		if count < s.cfg.CountMin {
+			return nil
+		}
+
+		rankKey = binary.BigEndian.AppendUint64(rankKey[:0], uint64(math.MaxInt64-count))
+		rankKey = append(rankKey, key...)
+
+		return ranked.Add(rankKey, 1)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to merge candidate counts: %w", err)
+	}
+
+	// Release the first table before the second merge.
+	_ = s.counter.Close()
+
+	var (
+		written int64
+		line    []byte
+	)
+
+	err = ranked.Merge(func(key []byte, _ int64) error {
+		if s.cfg.CountTop > 0 && written >= int64(s.cfg.CountTop) {
+			return errStopMerge
+		}
+
+		count := math.MaxInt64 - int64(binary.BigEndian.Uint64(key[:8]))
+
+		line = line[:0]
+		if s.cfg.CountFormat == CountFormatTSV {
+			line = strconv.AppendInt(line, count, 10)
+			line = append(line, '\t')
+		}
+		line = append(line, key[8:]...)
+		line = append(line, '\n')
+
+		if _, err := s.writer.Write(line); err != nil {
+			return err
+		}
+
+		written++
+		s.counters.recordOutput(key[8:])
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopMerge) {
+		return fmt.Errorf("failed to rank candidate counts: %w", err)
+	}
+
+	return s.writer.Flush()
+}
@@ -0,0 +1,752 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Pipeline runs the brainstorm transformation over arbitrary readers and
+// writers. It holds no process-global state, so several pipelines may run
+// concurrently within one program.
+type Pipeline struct {
+	cfg *structs.Config
+}
+
+// NewPipeline creates a pipeline for the given configuration. The
+// configuration must not be modified while a run is in progress.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// *Pipeline - Pipeline ready to run.
+func NewPipeline(cfg *structs.Config) *Pipeline {
+	return &Pipeline{cfg: cfg}
+}
+
+// Transform returns the candidates produced for a single input line. The
+// sequence is computed eagerly when Transform is called and may be iterated
+// any number of times.
+//
+// Args:
+// line: string - Raw input line (without trailing newline).
+//
+// Returns:
+// iter.Seq[string] - Sequence of output candidates.
+func (p *Pipeline) Transform(line string) iter.Seq[string] {
+	processed := TransformLine(p.cfg, []byte(line))
+
+	return func(yield func(string) bool) {
+		for candidate := range bytes.SplitSeq(processed, []byte{'\n'}) {
+			if len(candidate) == 0 {
+				continue
+			}
+
+			if !yield(string(candidate)) {
+				return
+			}
+		}
+	}
+}
+
+// Run reads newline-delimited lines from r, processes them concurrently
+// without preserving order, and writes candidates to w. Compressed input is
+// decompressed transparently. Cancelling ctx stops reading; lines already
+// queued are still processed and written, the output is flushed, and the
+// context error is returned. A failed write to w also stops reading; queued
+// lines are then discarded and the write error is returned.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// r: io.Reader - Input stream.
+// w: io.Writer - Output stream.
+//
+// Returns:
+// structs.Stats - Statistics for the run.
+// error - Any error encountered during processing.
+func (p *Pipeline) Run(ctx context.Context, r io.Reader, w io.Writer) (structs.Stats, error) {
+	source := inputSource{
+		Name: "input",
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	}
+
+	return p.run(ctx, []inputSource{source}, w)
+}
+
+// RunFiles behaves like Run but reads each file in order through a single
+// shared worker pool.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// paths: []string - Files to read.
+// w: io.Writer - Output stream.
+//
+// Returns:
+// structs.Stats - Statistics for the run.
+// error - Any error encountered during processing.
+func (p *Pipeline) RunFiles(ctx context.Context, paths []string, w io.Writer) (structs.Stats, error) {
+	sources := make([]inputSource, 0, len(paths))
+
+	for _, path := range paths {
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+
+		sources = append(sources, inputSource{
+			Name: path,
+			Size: size,
+			Open: func() (io.ReadCloser, error) {
+				file, err := os.Open(path)
+				if err != nil {
+					return nil, fmt.Errorf("failed to open input %q: %w", path, err)
+				}
+
+				return file, nil
+			},
+		})
+	}
+
+	return p.run(ctx, sources, w)
+}
+
+// inputSource is a named input stream consumed by a pipeline run. Size is
+// the stream's length in bytes, or 0 when it is unknown.
+type inputSource struct {
+	Name string
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// writeFailure records the first output error of a run and cancels the run
+// so that reading stops. It is safe for concurrent use.
+type writeFailure struct {
+	mu     sync.Mutex
+	err    error
+	cancel context.CancelCauseFunc
+}
+
+// Set records err unless an earlier error was recorded, and cancels the run.
+//
+// Args:
+// err: error - Output error.
+func (f *writeFailure) Set(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err == nil {
+		f.err = err
+		f.cancel(err)
+	}
+}
+
+// Err returns the recorded error, or nil if output has not failed.
+//
+// Returns:
+// error - First output error of the run.
+func (f *writeFailure) Err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.err
+}
+
+// runCounters holds the counters shared by the reader, the workers, and the
+// output sink.
+type runCounters struct {
+	inputs        atomic.Int64
+	linesRead     atomic.Int64
+	bytesRead     atomic.Int64
+	candidates    atomic.Int64
+	duplicates    atomic.Int64
+	inputRead     atomic.Int64
+	linesSplit    atomic.Int64
+	splitPieces   atomic.Int64
+	outputLengths [maxTrackedLength + 1]atomic.Int64
+}
+
+// run drives the worker pool over the given sources and passes candidates to
+// the configured output sink.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// sources: []inputSource - Inputs to read, in order.
+// w: io.Writer - Output stream.
+//
+// Returns:
+// structs.Stats - Statistics for the run.
+// error - Any error encountered during processing.
+func (p *Pipeline) run(ctx context.Context, sources []inputSource, w io.Writer) (structs.Stats, error) {
+	start := time.Now()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	failure := &writeFailure{cancel: cancel}
+
+	var counters runCounters
+
+	if err := validateMode(p.cfg.Mode); err != nil {
+		return structs.Stats{}, err
+	}
+
+	matcher, err := newHashMatcher(p.cfg)
+	if err != nil {
+		return structs.Stats{}, err
+	}
+
+	exclude, err := newExcludeFilter(p.cfg)
+	if err != nil {
+		return structs.Stats{}, err
+	}
+
+	if exclude != nil {
+		defer exclude.Close()
+	}
+
+	var (
+		resume      inputPosition
+		outputStart int64
+		baseOutput  int64
+		output      *countingWriter
+	)
+
+	if p.cfg.Checkpoint != "" {
+		outputStart = outputFileSize(w)
+
+		if p.cfg.Resume {
+			state, err := loadCheckpoint(p.cfg.Checkpoint)
+			if err != nil {
+				return structs.Stats{}, err
+			}
+
+			if state != nil {
+				if resume, err = state.resumePosition(sources); err != nil {
+					return structs.Stats{}, err
+				}
+				outputStart, baseOutput = state.OutputStart, state.OutputBytes
+			}
+		}
+
+		output = &countingWriter{writer: w}
+		w = output
+	}
+
+	rejects, err := newRejectsWriter(p.cfg)
+	if err != nil {
+		return structs.Stats{}, err
+	}
+
+	var recordReject func(text string, why rejection)
+	if rejects != nil {
+		recordReject = rejects.Record
+	}
+
+	sink, err := p.newOutputSink(w, &counters)
+	if err != nil {
+		if rejects != nil {
+			_ = rejects.Close()
+		}
+		return structs.Stats{}, err
+	}
+
+	// Checkpoints need the committed position that only in-order commits
+	// provide, so they imply ordered output. The order window counts lines,
+	// while the reorder buffer holds whole batches.
+	ordered := p.cfg.Ordered || p.cfg.Checkpoint != ""
+	perBatch := batchLines(ordered, p.cfg.OrderWindow)
+
+	var reorder *reorderBuffer
+	if ordered {
+		reorder = newReorderBuffer(sink, p.cfg.OrderWindow/perBatch, resume)
+	}
+
+	var checkpoint *checkpointer
+	if p.cfg.Checkpoint != "" {
+		flushable, ok := sink.(flusher)
+		if !ok {
+			_ = sink.Close()
+			if rejects != nil {
+				_ = rejects.Close()
+			}
+			return structs.Stats{}, errors.New("checkpoints need streamed output and cannot be combined with disk deduplication or counting")
+		}
+
+		checkpoint = &checkpointer{
+			path:        p.cfg.Checkpoint,
+			sources:     sources,
+			reorder:     reorder,
+			sink:        flushable,
+			output:      output,
+			outputStart: outputStart,
+			baseOutput:  baseOutput,
+			fail:        failure.Set,
+		}
+	}
+
+	// The queue holds whole batches: -queue lines rounded up, and at least
+	// one batch per worker so that no worker waits on a short queue.
+	workers := workerCount(p.cfg)
+	batchCh := make(chan *lineBatch, max(workers, (queueSize(p.cfg)+perBatch-1)/perBatch))
+
+	var progress *progressReporter
+	if p.cfg.Progress {
+		var totalSize int64
+		for _, source := range sources {
+			if source.Size <= 0 {
+				totalSize = 0
+				break
+			}
+			totalSize += source.Size
+		}
+
+		progress = startProgress(&counters, batchCh, totalSize)
+	}
+
+	if checkpoint != nil {
+		checkpoint.start()
+	}
+
+	traces := make([]*transformTrace, workers)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		trace := &transformTrace{reject: recordReject, counts: newStageStats()}
+		traces[i] = trace
+
+		go func() {
+			defer wg.Done()
+
+			for batch := range batchCh {
+				// Once the output has failed there is nowhere to write to;
+				// drain the queue so that the reader is never blocked.
+				if failure.Err() != nil {
+					releaseLineBatch(batch)
+					continue
+				}
+
+				chunk := newOutputChunk()
+				for line := range batch.Lines() {
+					chunk.AppendCandidates(transformCandidates(p.cfg, line, trace))
+				}
+
+				seq, end := batch.Seq, batch.End
+				releaseLineBatch(batch)
+
+				if exclude != nil && len(chunk.data) > 0 {
+					chunk.data = exclude.Filter(chunk.data)
+				}
+
+				if matcher != nil && len(chunk.data) > 0 {
+					chunk.data = matcher.Match(chunk.data)
+				}
+
+				if reorder != nil {
+					if err := reorder.Complete(seq, end, chunk); err != nil {
+						failure.Set(fmt.Errorf("error writing output: %w", err))
+					}
+					continue
+				}
+
+				if len(chunk.data) > 0 {
+					if err := sink.Emit(chunk.data); err != nil {
+						failure.Set(fmt.Errorf("error writing output: %w", err))
+					}
+				}
+
+				releaseOutputChunk(chunk)
+			}
+		}()
+	}
+
+	feed := &feeder{
+		batchCh:    batchCh,
+		batchLines: perBatch,
+		counters:   &counters,
+		reorder:    reorder,
+		resume:     resume,
+		bufferSize: bufferSize(p.cfg),
+		maxLine:    maxLineSize(p.cfg),
+		sentences:  p.cfg.Mode == ModeSentence,
+	}
+
+	readErr := feed.feedSources(ctx, sources)
+
+	close(batchCh)
+	wg.Wait()
+
+	if checkpoint != nil {
+		checkpoint.Stop()
+	}
+
+	closeErr := sink.Close()
+
+	var checkpointErr error
+	if checkpoint != nil {
+		checkpointErr = checkpoint.Save()
+	}
+
+	if progress != nil {
+		progress.Stop()
+	}
+
+	var rejectsErr error
+	if rejects != nil {
+		rejectsErr = rejects.Close()
+	}
+
+	stats := structs.Stats{
+		Workers:    workers,
+		Inputs:     int(counters.inputs.Load()),
+		LinesRead:  counters.linesRead.Load(),
+		BytesRead:  counters.bytesRead.Load(),
+		Candidates: counters.candidates.Load(),
+		Duplicates: counters.duplicates.Load(),
+		Duration:   time.Since(start),
+
+		LinesSplit:  counters.linesSplit.Load(),
+		SplitPieces: counters.splitPieces.Load(),
+
+		OutputLengths: counters.outputLengthHistogram(),
+	}
+
+	for _, trace := range traces {
+		trace.counts.mergeInto(&stats)
+	}
+
+	if exclude != nil {
+		stats.Excluded = exclude.Removed()
+	}
+
+	// A failed write cancels the reader, so it takes precedence over the
+	// cancellation it caused.
+	if err := failure.Err(); err != nil {
+		return stats, err
+	}
+
+	if readErr != nil {
+		return stats, readErr
+	}
+
+	if closeErr != nil {
+		return stats, fmt.Errorf("error writing output: %w", closeErr)
+	}
+
+	if rejectsErr != nil {
+		return stats, rejectsErr
+	}
+
+	if checkpointErr != nil {
+		return stats, checkpointErr
+	}
+
+	return stats, nil
+}
+
+// feeder reads input sources and queues their lines for the workers in
+// batches. Sources before the resume position are skipped, as is the part of
+// the resumed source that was already processed. Lines longer than maxLine
+// bytes are queued as several pieces. In sentence mode the text is queued
+// sentence by sentence instead of line by line.
+type feeder struct {
+	batchCh    chan<- *lineBatch
+	batchLines int
+	nextSeq    uint64
+	counters   *runCounters
+	reorder    *reorderBuffer
+	resume     inputPosition
+	bufferSize int
+	maxLine    int
+	sentences  bool
+
+	batch    *lineBatch
+	position inputPosition
+}
+
+// feedSources streams every source in order into the batch channel.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// sources: []inputSource - Inputs to read.
+//
+// Returns:
+// error - Error if a source cannot be opened or read, or ctx is cancelled.
+func (f *feeder) feedSources(ctx context.Context, sources []inputSource) error {
+	for index, source := range sources {
+		if index < f.resume.Source {
+			continue
+		}
+
+		input, err := source.Open()
+		if err != nil {
+			return err
+		}
+
+		f.counters.inputs.Add(1)
+
+		start := inputPosition{Source: index}
+		if index == f.resume.Source {
+			start.Offset = f.resume.Offset
+		}
+
+		counted := &countingReader{reader: input, count: &f.counters.inputRead}
+		err = f.feedLines(ctx, counted, source.Name, start)
+		_ = input.Close()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// feedLines reads newline-delimited text from a reader, transparently
+// decompressing gzip, bzip2, xz, and zstd input, and queues it on the batch
+// channel as lines or, in sentence mode, as sentences.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// source: io.Reader - Input to read.
+// name: string - Display name of the input used in error messages.
+// start: inputPosition - Source index, and the number of decompressed bytes
+// to skip before the first line.
+//
+// Returns:
+// error - Error if reading fails for a reason other than EOF, or ctx is
+// cancelled.
+func (f *feeder) feedLines(ctx context.Context, source io.Reader, name string, start inputPosition) error {
+	decompressed, closeDecompressor, err := openDecompressor(source, f.bufferSize)
+	if err != nil {
+		return fmt.Errorf("error reading from %s: %w", name, err)
+	}
+	defer closeDecompressor()
+
+	reader := bufio.NewReaderSize(decompressed, f.bufferSize)
+
+	if start.Offset > 0 {
+		if _, err := io.CopyN(io.Discard, reader, start.Offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("error skipping to the checkpoint in %s: %w", name, err)
+		}
+	}
+
+	f.position = start
+	f.batch = newLineBatch()
+	defer func() {
+		releaseLineBatch(f.batch)
+		f.batch = nil
+	}()
+
+	if f.sentences {
+		return f.readSentences(ctx, reader, name)
+	}
+
+	return f.readLines(ctx, reader, name)
+}
+
+// readLines queues the lines of a reader, without their trailing newlines.
+// A batch is sent once it is full, at the end of the input, or when no more
+// input is buffered, so that slow streams are not held back waiting for a
+// full batch.
+//
+// Lines longer than the maximum line size are split while they are read, so
+// memory stays bounded however long a line is. Each piece ends on the last
+// sentence or whitespace boundary that fits and is queued as a line of its
+// own.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// reader: *bufio.Reader - Decompressed input positioned at f.position.
+// name: string - Display name of the input used in error messages.
+//
+// Returns:
+// error - Error if reading fails for a reason other than EOF, or ctx is
+// cancelled.
+func (f *feeder) readLines(ctx context.Context, reader *bufio.Reader, name string) error {
+	var (
+		pending []byte
+		pieces  int64
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fragment, readErr := reader.ReadSlice('\n')
+		f.counters.bytesRead.Add(int64(len(fragment)))
+
+		// Lines longer than the read buffer arrive in pieces; only the part
+		// not yet queued is kept.
+		if errors.Is(readErr, bufio.ErrBufferFull) {
+			pending = append(pending, fragment...)
+
+			for len(pending) > f.maxLine {
+				cut := splitPoint(pending, f.maxLine)
+				if err := f.queue(ctx, pending[:cut], cut); err != nil {
+					return err
+				}
+
+				pending = pending[:copy(pending, pending[cut:])]
+				pieces++
+			}
+
+			if f.batch.Len() > 0 && reader.Buffered() == 0 {
+				if err := f.flush(ctx); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		line := fragment
+		if len(pending) > 0 {
+			pending = append(pending, fragment...)
+			line = pending
+		}
+
+		if len(line) > 0 {
+			content := bytes.TrimSuffix(line, []byte{'\n'})
+			newline := len(line) - len(content)
+
+			for len(content) > f.maxLine {
+				cut := splitPoint(content, f.maxLine)
+				if err := f.queue(ctx, content[:cut], cut); err != nil {
+					return err
+				}
+
+				content = content[cut:]
+				pieces++
+			}
+
+			if err := f.queue(ctx, content, len(content)+newline); err != nil {
+				return err
+			}
+
+			f.counters.linesRead.Add(1)
+
+			if pieces > 0 {
+				f.counters.linesSplit.Add(1)
+				f.counters.splitPieces.Add(pieces + 1)
+			}
+
+			pending, pieces = pending[:0], 0
+		}
+
+		if f.batch.Len() > 0 && (readErr != nil || reader.Buffered() == 0) {
+			if err := f.flush(ctx); err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("error reading from %s: %w", name, readErr)
+		}
+	}
+}
+
+// queue adds a line or a piece of a long line to the current batch and
+// sends the batch once it is full.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// line: []byte - Text to queue, without a trailing newline.
+// consumed: int - Input bytes the text covers, including a newline or
+// boundary whitespace that ends it.
+//
+// Returns:
+// error - Context error if ctx is cancelled while sending.
+func (f *feeder) queue(ctx context.Context, line []byte, consumed int) error {
+	f.position.Offset += int64(consumed)
+
+	f.batch.Append(line)
+	f.batch.End = f.position
+
+	if f.batch.Full(f.batchLines) {
+		return f.flush(ctx)
+	}
+
+	return nil
+}
+
+// skip advances past input that produces no line, such as the whitespace
+// between sentences.
+//
+// Args:
+// consumed: int - Input bytes to skip.
+func (f *feeder) skip(consumed int) {
+	f.position.Offset += int64(consumed)
+
+	if f.batch.Len() > 0 {
+		f.batch.End = f.position
+	}
+}
+
+// flush sends the current batch and starts a new one.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+//
+// Returns:
+// error - Context error if ctx is cancelled while sending.
+func (f *feeder) flush(ctx context.Context) error {
+	if err := f.send(ctx, f.batch); err != nil {
+		return err
+	}
+
+	f.batch = newLineBatch()
+
+	return nil
+}
+
+// send numbers a batch and queues it for the workers. In ordered mode the
+// batch first reserves a slot in the reorder window. The batch belongs to
+// the workers once it is queued.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// batch: *lineBatch - Batch to queue.
+//
+// Returns:
+// error - Context error if ctx is cancelled while waiting.
+func (f *feeder) send(ctx context.Context, batch *lineBatch) error {
+	if f.reorder != nil {
+		if err := f.reorder.Acquire(ctx); err != nil {
+			return err
+		}
+	}
+
+	batch.Seq = f.nextSeq
+
+	select {
+	case f.batchCh <- batch:
+		f.nextSeq++
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,264 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// testCorpus returns n lines of word-like prose.
+func testCorpus(n int) []byte {
+	words := []string{"river", "garden", "silver", "morning", "window", "mountain", "letter", "harbor", "candle", "forest"}
+
+	var b bytes.Buffer
+	for i := range n {
+		fmt.Fprintf(&b, "the %s near the %s and %s\n", words[i%len(words)], words[(i*3+1)%len(words)], words[(i*7+2)%len(words)])
+	}
+
+	return b.Bytes()
+}
+
+func testConfig() *structs.Config {
+	return &structs.Config{NGramMin: 1, NGramMax: 3, OutMinLength: 1, OutMaxLength: 64}
+}
+
+func TestPipelineOrderedMatchesTransform(t *testing.T) {
+	corpus := testCorpus(1000)
+
+	var want strings.Builder
+	for line := range bytes.Lines(corpus) {
+		for _, candidate := range transformCandidates(testConfig(), bytes.TrimSuffix(line, []byte{'\n'}), nil) {
+			want.WriteString(candidate + "\n")
+		}
+	}
+
+	// The order window caps the batch size, so these cover one line per
+	// batch, small batches, and full batches.
+	for _, window := range []int{1, 7, 4096} {
+		cfg := testConfig()
+		cfg.Ordered = true
+		cfg.OrderWindow = window
+
+		var got strings.Builder
+		stats, err := NewPipeline(cfg).Run(context.Background(), bytes.NewReader(corpus), &got)
+		if err != nil {
+			t.Fatalf("window %d: Run: %v", window, err)
+		}
+
+		if got.String() != want.String() {
+			t.Fatalf("window %d: ordered output differs from the sequential transform", window)
+		}
+
+		if stats.LinesRead != 1000 {
+			t.Fatalf("window %d: read %d lines, want 1000", window, stats.LinesRead)
+		}
+	}
+}
+
+func TestBatchLines(t *testing.T) {
+	cases := []struct {
+		ordered bool
+		window  int
+		want    int
+	}{
+		{false, 1, maxBatchLines},
+		{true, 1, 1},
+		{true, 0, 1},
+		{true, 100, 100},
+		{true, 1 << 20, maxBatchLines},
+	}
+
+	for _, tc := range cases {
+		if got := batchLines(tc.ordered, tc.window); got != tc.want {
+			t.Errorf("batchLines(%v, %d) = %d, want %d", tc.ordered, tc.window, got, tc.want)
+		}
+	}
+}
+
+func TestLineBatchFull(t *testing.T) {
+	batch := newLineBatch()
+	defer releaseLineBatch(batch)
+
+	for range 3 {
+		batch.Append([]byte("line"))
+	}
+
+	if batch.Full(4) || !batch.Full(3) {
+		t.Fatalf("Full misreports a batch of %d lines", batch.Len())
+	}
+
+	batch.Append(bytes.Repeat([]byte{'x'}, maxBatchBytes))
+	if !batch.Full(maxBatchLines) {
+		t.Fatal("batch over the byte limit not reported full")
+	}
+
+	var lines []string
+	for line := range batch.Lines() {
+		lines = append(lines, string(line[:min(len(line), 4)]))
+	}
+
+	if strings.Join(lines, ",") != "line,line,line,xxxx" {
+		t.Fatalf("lines %q", lines)
+	}
+}
+
+func TestPipelineCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewPipeline(testConfig()).Run(ctx, bytes.NewReader(testCorpus(100)), io.Discard)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled run returned %v", err)
+	}
+}
+
+// runPerLine reproduces the original processing loop: every line is its own
+// task on the worker channel, and each worker writes its candidates under a
+// shared mutex.
+func runPerLine(cfg *structs.Config, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReaderSize(r, 1<<20)
+	writer := bufio.NewWriterSize(w, 1<<20)
+
+	var writeMu sync.Mutex
+
+	taskCh := make(chan []byte, 1024)
+
+	var wg sync.WaitGroup
+	for range runtime.NumCPU() {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for line := range taskCh {
+				processed := TransformLine(cfg, line)
+				if len(processed) == 0 {
+					continue
+				}
+
+				writeMu.Lock()
+				_, _ = writer.Write(processed)
+				_ = writer.WriteByte('\n')
+				writeMu.Unlock()
+			}
+		}()
+	}
+
+	for {
+		raw, err := reader.ReadBytes('\n')
+		if len(raw) > 0 {
+			taskCh <- bytes.TrimSuffix(raw, []byte{'\n'})
+		}
+
+		if err != nil {
+			close(taskCh)
+			wg.Wait()
+
+			if errors.Is(err, io.EOF) {
+				return writer.Flush()
+			}
+
+			return err
+		}
+	}
+}
+
+// BenchmarkPipeline compares the original per-line task and locked write
+// path with the batched pipeline. Single-word n-grams keep the transform
+// cheap, so the per-task overhead that batching removes dominates.
+func BenchmarkPipeline(b *testing.B) {
+	corpus := testCorpus(20000)
+
+	cfg := testConfig()
+	cfg.NGramMax = 1
+
+	b.Run("per-line", func(b *testing.B) {
+		b.SetBytes(int64(len(corpus)))
+
+		for b.Loop() {
+			if err := runPerLine(cfg, bytes.NewReader(corpus), io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("batched", func(b *testing.B) {
+		pipeline := NewPipeline(cfg)
+
+		b.SetBytes(int64(len(corpus)))
+
+		for b.Loop() {
+			if _, err := pipeline.Run(context.Background(), bytes.NewReader(corpus), io.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// brokenPipeWriter accepts limit bytes and then fails every write with
+// EPIPE, as stdout does once the reader of a pipe has exited.
+type brokenPipeWriter struct {
+	mu      sync.Mutex
+	limit   int
+	written int
+	fails   int
+}
+
+func (w *brokenPipeWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.written+len(p) > w.limit {
+		w.fails++
+		return 0, syscall.EPIPE
+	}
+
+	w.written += len(p)
+
+	return len(p), nil
+}
+
+func TestPipelineStopsOnBrokenPipe(t *testing.T) {
+	corpus := testCorpus(200000)
+
+	cfg := testConfig()
+	cfg.BufferSize = 4096
+
+	w := &brokenPipeWriter{limit: 256 << 10}
+
+	stats, err := NewPipeline(cfg).Run(context.Background(), bytes.NewReader(corpus), w)
+	if !errors.Is(err, syscall.EPIPE) {
+		t.Fatalf("Run returned %v, want an error wrapping EPIPE", err)
+	}
+
+	// The run stops reading soon after the failure instead of transforming
+	// the whole input, and stops writing after the first failed write.
+	if stats.LinesRead >= 200000 {
+		t.Fatalf("read all %d lines after the output failed", stats.LinesRead)
+	}
+
+	if w.fails != 1 {
+		t.Fatalf("%d writes failed, want the run to stop after the first", w.fails)
+	}
+
+	// Candidates accepted into the output buffer before the failure are
+	// counted, so the count covers at most one buffer beyond what reached
+	// the writer.
+	if stats.Candidates == 0 {
+		t.Fatal("no candidates counted before the failure")
+	}
+
+	if int(stats.Candidates) > w.written+cfg.BufferSize {
+		t.Fatalf("counted %d candidates but only %d bytes were written", stats.Candidates, w.written)
+	}
+}
@@ -0,0 +1,156 @@
+package mutate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is the time between progress reports. Reports only read
+// the shared counters, so the workers never wait on them.
+const progressInterval = 2 * time.Second
+
+// progressReporter periodically prints the state of a run to stderr.
+type progressReporter struct {
+	counters  *runCounters
+	queue     chan *lineBatch
+	totalSize int64
+	start     time.Time
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+// startProgress starts reporting progress until Stop is called.
+//
+// Args:
+// counters: *runCounters - Counters of the run.
+// queue: chan *lineBatch - Worker queue whose depth is reported.
+// totalSize: int64 - Combined size of the inputs in bytes, or 0 if unknown.
+//
+// Returns:
+// *progressReporter - Running reporter.
+func startProgress(counters *runCounters, queue chan *lineBatch, totalSize int64) *progressReporter {
+	r := &progressReporter{
+		counters:  counters,
+		queue:     queue,
+		totalSize: totalSize,
+		start:     time.Now(),
+		stop:      make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go r.loop()
+
+	return r
+}
+
+// loop prints a report on every tick.
+func (r *progressReporter) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	lastLines, lastTime := int64(0), r.start
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			lines := r.counters.linesRead.Load()
+			rate := float64(lines-lastLines) / now.Sub(lastTime).Seconds()
+			lastLines, lastTime = lines, now
+
+			fmt.Fprintf(os.Stderr, "[*] Progress: %s.\n", r.format(lines, rate, now))
+		}
+	}
+}
+
+// format builds a single progress report.
+//
+// Args:
+// lines: int64 - Lines read so far.
+// rate: float64 - Lines read per second since the previous report.
+// now: time.Time - Time of the report.
+//
+// Returns:
+// string - Report text.
+func (r *progressReporter) format(lines int64, rate float64, now time.Time) string {
+	parts := []string{
+		fmt.Sprintf("%d lines", lines),
+		fmt.Sprintf("%s read", formatSize(r.counters.bytesRead.Load())),
+		fmt.Sprintf("%d candidates", r.counters.candidates.Load()),
+		fmt.Sprintf("%.0f lines/s", rate),
+		fmt.Sprintf("queue %d/%d batches", len(r.queue), cap(r.queue)),
+	}
+
+	if r.totalSize > 0 {
+		consumed := min(r.counters.inputRead.Load(), r.totalSize)
+		fraction := float64(consumed) / float64(r.totalSize)
+		parts = append(parts, fmt.Sprintf("%.1f%%", fraction*100))
+
+		if fraction > 0 {
+			elapsed := now.Sub(r.start)
+			remaining := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+			parts = append(parts, "ETA "+remaining.Round(time.Second).String())
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Stop ends progress reporting and waits for the reporter to exit.
+func (r *progressReporter) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// formatSize formats a byte count with a binary unit.
+//
+// Args:
+// size: int64 - Size in bytes.
+//
+// Returns:
+// string - Formatted size, for example "1.5 GiB".
+func formatSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
+
+// countingReader counts the bytes read from an input before decompression,
+// so that progress can be measured against the input's size on disk.
+type countingReader struct {
+	reader io.Reader
+	count  *atomic.Int64
+}
+
+// Read reads from the underlying reader and counts the bytes returned.
+//
+// Args:
+// p: []byte - Destination buffer.
+//
+// Returns:
+// int - Number of bytes read.
+// error - Error returned by the underlying reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.count.Add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,395 @@
+package mutate
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Limits of the hashcat rule engine.
+const (
+	maxRuleFunctions = 31
+	maxRuleLength    = 255
+)
+
+// ruleArity maps each hashcat rule function to its number of parameters.
+var ruleArity = map[byte]int{
+	':': 0, 'l': 0, 'u': 0, 'c': 0, 'C': 0, 't': 0, 'T': 1, 'r': 0, 'd': 0,
+	'p': 1, 'f': 0, '{': 0, '}': 0, '$': 1, '^': 1, '[': 0, ']': 0, 'D': 1,
+	'x': 2, 'O': 2, 'i': 2, 'o': 2, '\'': 1, 's': 2, '@': 1, 'z': 1, 'Z': 1,
+	'q': 0, 'k': 0, 'K': 0, '*': 2, 'L': 1, 'R': 1, '+': 1, '-': 1, '.': 1,
+	',': 1, 'y': 1, 'Y': 1, 'E': 0, 'e': 1, '3': 2,
+}
+
+// rulesUsePositions lists the rule functions whose parameters are positions
+// encoded as 0-9 and A-Z.
+var rulesUsePositions = map[byte]int{
+	'T': 1, 'p': 1, 'D': 1, 'x': 2, 'O': 2, 'i': 1, 'o': 1, '\'': 1, 'z': 1, 'Z': 1,
+	'*': 2, 'L': 1, 'R': 1, '+': 1, '-': 1, '.': 1, ',': 1, 'y': 1, 'Y': 1, '3': 1,
+}
+
+// ValidateRule checks a rule against the hashcat rule function set and its
+// limits on functions per rule and rule length.
+//
+// Args:
+// rule (string): Rule line, with functions optionally separated by spaces.
+//
+// Returns:
+// error: Error describing the first problem found.
+func ValidateRule(rule string) error {
+	if len(rule) > maxRuleLength {
+		return fmt.Errorf("rule is %d bytes long, the limit is %d", len(rule), maxRuleLength)
+	}
+
+	functions := 0
+
+	for i := 0; i < len(rule); {
+		op := rule[i]
+
+		if op == ' ' {
+			i++
+			continue
+		}
+
+		arity, known := ruleArity[op]
+		if !known {
+			return fmt.Errorf("unknown rule function %q at offset %d", op, i)
+		}
+
+		if i+arity >= len(rule) {
+			return fmt.Errorf("rule function %q at offset %d is missing parameters", op, i)
+		}
+
+		for p := 0; p < rulesUsePositions[op]; p++ {
+			if !isRulePosition(rule[i+1+p]) {
+				return fmt.Errorf("rule function %q at offset %d has invalid position %q", op, i, rule[i+1+p])
+			}
+		}
+
+		functions++
+		i += 1 + arity
+	}
+
+	if functions > maxRuleFunctions {
+		return fmt.Errorf("rule has %d functions, the limit is %d", functions, maxRuleFunctions)
+	}
+
+	return nil
+}
+
+// isRulePosition reports whether a byte encodes a rule position (0-9, A-Z).
+//
+// Args:
+// b (byte): Parameter byte.
+//
+// Returns:
+// bool: True if the byte is a valid position.
+func isRulePosition(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'A' && b <= 'Z')
+}
+
+// rulesBaseStyles returns the case styles of the base candidates written in
+// rules mode. Title casing keeps the rest of each word and leaves single
+// words as-is, which no hashcat rule function reproduces, so title-styled
+// base candidates are written instead and the title rule becomes ":".
+// When both title and original are requested, both bases are written; the
+// other styles then yield some duplicate candidates, but every requested
+// variant is still produced.
+//
+// Args:
+// styles ([]string): Requested case styles; empty means title.
+//
+// Returns:
+// []string: Case styles to apply to base candidates.
+func rulesBaseStyles(styles []string) []string {
+	if len(styles) == 0 {
+		return []string{StyleTitle}
+	}
+
+	var base []string
+	for _, style := range []string{StyleOriginal, StyleTitle} {
+		if slices.Contains(styles, style) {
+			base = append(base, style)
+		}
+	}
+
+	if len(base) == 0 {
+		return []string{StyleOriginal}
+	}
+
+	return base
+}
+
+// caseStyleRule returns the rule reproducing a case style on a base
+// candidate whose words are separated by spaces. Title-styled base
+// candidates are already written for the title style (see rulesBaseStyles).
+//
+// Args:
+// style (string): Case style.
+//
+// Returns:
+// string: Rule functions for the style.
+func caseStyleRule(style string) string {
+	switch style {
+	case StyleLower:
+		return "l"
+	case StyleUpper:
+		return "u"
+	case StyleCamel:
+		return "E T0"
+	case StyleSentence:
+		return "c"
+	}
+
+	return ":"
+}
+
+// separatorRule returns the rule replacing the spaces of a base candidate
+// with a separator.
+//
+// Args:
+// sep (string): Separator, at most one byte long.
+//
+// Returns:
+// string: Rule functions for the separator, or "" for a space.
+func separatorRule(sep string) string {
+	switch sep {
+	case "":
+		return "@ "
+	case " ":
+		return ""
+	}
+
+	return "s " + sep
+}
+
+// substitutionRules returns the substitution rule combinations of the "all"
+// leet mode. Rule substitutions replace every occurrence of a letter, in
+// both cases, rather than individual positions. Combinations are built
+// directly for one letter, then two, up to every letter of the table, so
+// the work is bounded by the rules produced rather than the full product of
+// every letter's choices.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+//
+// Returns:
+// []string: Substitution rules, excluding the empty rule.
+func substitutionRules(cfg *structs.Config) []string {
+	if cfg.LeetMode == LeetOff {
+		return nil
+	}
+
+	table := cfg.LeetTable
+	if table == nil {
+		table = DefaultSubstitutionTable()
+	}
+
+	letters := slices.Sorted(maps.Keys(table))
+
+	substitute := func(letter rune, replacement string) string {
+		var parts []string
+		for _, r := range []rune{letter, unicode.ToUpper(letter)} {
+			if utf8.RuneLen(r) != 1 || len(replacement) != 1 {
+				continue
+			}
+			part := "s" + string(r) + replacement
+			if !slices.Contains(parts, part) {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, " ")
+	}
+
+	// Letters whose replacements cannot be written as rules are dropped up
+	// front, so every combination below yields a rule.
+	var (
+		usable []rune
+		parts  = make(map[rune][]string)
+	)
+
+	for _, letter := range letters {
+		for _, replacement := range table[letter] {
+			if part := substitute(letter, replacement); part != "" {
+				parts[letter] = append(parts[letter], part)
+			}
+		}
+
+		if len(parts[letter]) > 0 {
+			usable = append(usable, letter)
+		}
+	}
+
+	var (
+		rules  []string
+		chosen []string
+	)
+
+	// combine appends every rule substituting exactly k more letters taken
+	// from usable[from:], reporting false once the variant cap is reached.
+	var combine func(from, k int) bool
+	combine = func(from, k int) bool {
+		if k == 0 {
+			rules = append(rules, strings.Join(chosen, " "))
+			return cfg.LeetMaxVariants <= 0 || len(rules) < cfg.LeetMaxVariants
+		}
+
+		for i := from; i <= len(usable)-k; i++ {
+			for _, part := range parts[usable[i]] {
+				chosen = append(chosen, part)
+				more := combine(i+1, k-1)
+				chosen = chosen[:len(chosen)-1]
+
+				if !more {
+					return false
+				}
+			}
+		}
+
+		return true
+	}
+
+	for k := 1; k <= len(usable); k++ {
+		if !combine(0, k) {
+			break
+		}
+	}
+
+	return rules
+}
+
+// validateRulesLeetMode checks that a leet mode can be expressed as rules.
+// The "single" and "first" modes substitute chosen positions of each
+// candidate, which depend on where its substitutable letters are; a rule
+// applies the same functions to every base candidate, so neither whole-letter
+// nor positional rules reproduce them.
+//
+// Args:
+// mode (string): Leet mode.
+//
+// Returns:
+// error: Error if the mode has no rule equivalent.
+func validateRulesLeetMode(mode string) error {
+	switch mode {
+	case LeetSingle, LeetFirst:
+		return fmt.Errorf("leet mode %q cannot be expressed as rules; use %q or expand variants inline", mode, LeetAll)
+	}
+
+	return nil
+}
+
+// affixRules returns one rule per configured prefix and suffix, plus the
+// empty rule for unaffixed candidates. Like the inline affix stage, a
+// candidate gets a prefix or a suffix, never both.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+//
+// Returns:
+// []string: Affix rules.
+func affixRules(cfg *structs.Config) []string {
+	rules := []string{""}
+
+	for _, prefix := range cfg.Prefixes {
+		var b strings.Builder
+		for i := len(prefix) - 1; i >= 0; i-- {
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteByte('^')
+			b.WriteByte(prefix[i])
+		}
+		rules = append(rules, b.String())
+	}
+
+	for _, suffix := range cfg.Suffixes {
+		var b strings.Builder
+		for i := 0; i < len(suffix); i++ {
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteByte('$')
+			b.WriteByte(suffix[i])
+		}
+		rules = append(rules, b.String())
+	}
+
+	return rules
+}
+
+// GenerateRules builds the hashcat rules that reproduce the configured case
+// styles, separators, substitutions, and affixes on the base candidates
+// written in rules mode, validating each rule.
+//
+// Args:
+// cfg (*structs.Config): Configuration.
+//
+// Returns:
+// []string: De-duplicated rules in generation order.
+// error: Error if the leet mode or a separator cannot be expressed as
+// rules, or a rule exceeds the hashcat limits.
+func GenerateRules(cfg *structs.Config) ([]string, error) {
+	if err := validateRulesLeetMode(cfg.LeetMode); err != nil {
+		return nil, err
+	}
+
+	styles := cfg.CaseStyles
+	if len(styles) == 0 {
+		styles = []string{StyleTitle}
+	}
+
+	separators := cfg.Separators
+	if len(separators) == 0 {
+		separators = []string{""}
+	}
+
+	for _, sep := range separators {
+		if len(sep) > 1 {
+			return nil, fmt.Errorf("separator %q cannot be expressed as a rule; rules support single-byte separators", sep)
+		}
+	}
+
+	substitutions := append([]string{""}, substitutionRules(cfg)...)
+	affixes := affixRules(cfg)
+
+	var rules []string
+	seen := make(map[string]struct{})
+
+	for _, style := range styles {
+		for _, sep := range separators {
+			for _, substitution := range substitutions {
+				for _, affix := range affixes {
+					var parts []string
+					for _, part := range []string{caseStyleRule(style), separatorRule(sep), substitution, affix} {
+						if part != "" && part != ":" {
+							parts = append(parts, part)
+						}
+					}
+
+					rule := strings.Join(parts, " ")
+					if rule == "" {
+						rule = ":"
+					}
+
+					if _, exists := seen[rule]; exists {
+						continue
+					}
+
+					if err := ValidateRule(rule); err != nil {
+						return nil, fmt.Errorf("invalid generated rule %q: %w", rule, err)
+					}
+
+					seen[rule] = struct{}{}
+					rules = append(rules, rule)
+				}
+			}
+		}
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,253 @@
+package mutate
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+func TestSubstitutionRulesLargeTable(t *testing.T) {
+	table := make(map[rune][]string)
+	for r := 'a'; r <= 'z'; r++ {
+		table[r] = []string{"1", "2"}
+	}
+
+	cfg := &structs.Config{LeetMode: LeetAll, LeetTable: table, LeetMaxVariants: 52}
+
+	done := make(chan []string, 1)
+	go func() { done <- substitutionRules(cfg) }()
+
+	select {
+	case rules := <-done:
+		if len(rules) != 52 {
+			t.Fatalf("got %d rules, want 52", len(rules))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("substitutionRules did not finish")
+	}
+}
+
+func TestSubstitutionRulesAll(t *testing.T) {
+	cfg := &structs.Config{
+		LeetMode:  LeetAll,
+		LeetTable: map[rune][]string{'a': {"4", "@"}, 'e': {"3"}, 'o': {"0"}},
+	}
+
+	rules := substitutionRules(cfg)
+
+	// 4 single-letter rules, 5 two-letter rules, and 2 three-letter rules.
+	if len(rules) != 11 {
+		t.Fatalf("got %d rules, want 11: %q", len(rules), rules)
+	}
+
+	for _, want := range []string{"sa4 sA4", "sa@ sA@ se3 sE3", "sa4 sA4 se3 sE3 so0 sO0"} {
+		if !slices.Contains(rules, want) {
+			t.Errorf("missing rule %q in %q", want, rules)
+		}
+	}
+
+	cfg.LeetMaxVariants = 3
+	if got := substitutionRules(cfg); len(got) != 3 {
+		t.Fatalf("got %d rules with a cap of 3", len(got))
+	}
+}
+
+func TestGenerateRulesRejectsPositionalLeet(t *testing.T) {
+	for _, mode := range []string{LeetSingle, LeetFirst} {
+		cfg := &structs.Config{LeetMode: mode, LeetPositions: 2}
+
+		if _, err := GenerateRules(cfg); err == nil {
+			t.Errorf("GenerateRules accepted leet mode %q", mode)
+		}
+	}
+}
+
+// applyRule applies the subset of hashcat rule functions GenerateRules
+// emits to a word.
+func applyRule(t *testing.T, rule, word string) string {
+	t.Helper()
+
+	b := []byte(word)
+
+	for i := 0; i < len(rule); {
+		op := rule[i]
+		i++
+
+		switch op {
+		case ' ', ':':
+		case 'l':
+			b = bytes.ToLower(b)
+		case 'u':
+			b = bytes.ToUpper(b)
+		case 'c':
+			b = bytes.ToLower(b)
+			if len(b) > 0 {
+				b[0] = byte(unicode.ToUpper(rune(b[0])))
+			}
+		case 'E':
+			b = bytes.ToLower(b)
+			for j := range b {
+				if j == 0 || b[j-1] == ' ' {
+					b[j] = byte(unicode.ToUpper(rune(b[j])))
+				}
+			}
+		case 'T':
+			if pos := int(rule[i] - '0'); pos < len(b) {
+				r := rune(b[pos])
+				if unicode.IsUpper(r) {
+					b[pos] = byte(unicode.ToLower(r))
+				} else {
+					b[pos] = byte(unicode.ToUpper(r))
+				}
+			}
+			i++
+		case '@':
+			b = bytes.ReplaceAll(b, []byte{rule[i]}, nil)
+			i++
+		case 's':
+			b = bytes.ReplaceAll(b, []byte{rule[i]}, []byte{rule[i+1]})
+			i += 2
+		case '$':
+			b = append(b, rule[i])
+			i++
+		case '^':
+			b = append([]byte{rule[i]}, b...)
+			i++
+		default:
+			t.Fatalf("applyRule: unsupported function %q in %q", op, rule)
+		}
+	}
+
+	return string(b)
+}
+
+func TestRulesReproduceInlineVariants(t *testing.T) {
+	// The line holds every substitutable letter at most once, where
+	// whole-letter rules and positional substitutions agree.
+	line := []byte("Brave Cost mind")
+
+	cases := []struct {
+		name string
+		cfg  structs.Config
+	}{
+		{"default", structs.Config{}},
+		{"styles", structs.Config{
+			CaseStyles: []string{StyleLower, StyleUpper, StyleTitle, StyleCamel, StyleSentence, StyleOriginal},
+			Separators: []string{"", "_", " "},
+		}},
+		{"leet", structs.Config{
+			CaseStyles: []string{StyleLower, StyleTitle},
+			LeetMode:   LeetAll,
+			LeetTable:  map[rune][]string{'a': {"4", "@"}, 'e': {"3"}, 'o': {"0"}},
+		}},
+		{"affixes", structs.Config{
+			CaseStyles: []string{StyleLower, StyleCamel},
+			Separators: []string{"", "-"},
+			LeetMode:   LeetAll,
+			LeetTable:  map[rune][]string{'e': {"3"}, 'i': {"1", "!"}},
+			Prefixes:   []string{"1", "#!"},
+			Suffixes:   []string{"2024", "!"},
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			inline := tc.cfg
+			inline.NGramMin, inline.NGramMax = 1, 3
+			inline.OutMinLength, inline.OutMaxLength = 1, 64
+
+			rulesCfg := inline
+			rulesCfg.RulesOut = "unused.rule"
+
+			rules, err := GenerateRules(&rulesCfg)
+			if err != nil {
+				t.Fatalf("GenerateRules: %v", err)
+			}
+
+			got := make(map[string]struct{})
+			for _, base := range transformCandidates(&rulesCfg, line, nil) {
+				for _, rule := range rules {
+					got[applyRule(t, rule, base)] = struct{}{}
+				}
+			}
+
+			want := make(map[string]struct{})
+			for _, candidate := range transformCandidates(&inline, line, nil) {
+				want[candidate] = struct{}{}
+			}
+
+			for candidate := range want {
+				if _, exists := got[candidate]; !exists {
+					t.Errorf("inline candidate %q not reproduced by the rules", candidate)
+				}
+			}
+
+			for candidate := range got {
+				if _, exists := want[candidate]; !exists {
+					t.Errorf("rules produced %q, which the inline expansion does not", candidate)
+				}
+			}
+		})
+	}
+}
+
+func TestRulesModeTitleBase(t *testing.T) {
+	cfg := &structs.Config{
+		RulesOut:     "unused.rule",
+		NGramMin:     1,
+		NGramMax:     2,
+		OutMaxLength: 64,
+	}
+
+	got := transformCandidates(cfg, []byte("hello WORLD"), nil)
+	want := []string{"hello", "WORLD", "Hello WORLD"}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("base candidates %q, want %q", got, want)
+	}
+
+	rules, err := GenerateRules(cfg)
+	if err != nil {
+		t.Fatalf("GenerateRules: %v", err)
+	}
+
+	if !slices.Equal(rules, []string{"@ "}) {
+		t.Fatalf("rules %q, want [\"@ \"]", rules)
+	}
+}
+
+func TestRulesBaseStyles(t *testing.T) {
+	cases := []struct {
+		styles []string
+		want   []string
+	}{
+		{nil, []string{StyleTitle}},
+		{[]string{StyleLower, StyleUpper}, []string{StyleOriginal}},
+		{[]string{StyleTitle, StyleLower}, []string{StyleTitle}},
+		{[]string{StyleTitle, StyleOriginal}, []string{StyleOriginal, StyleTitle}},
+	}
+
+	for _, tc := range cases {
+		if got := rulesBaseStyles(tc.styles); !slices.Equal(got, tc.want) {
+			t.Errorf("rulesBaseStyles(%q) = %q, want %q", tc.styles, got, tc.want)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	for _, rule := range []string{":", "E T0", "sa4 sA4 $1 ^x", "@ "} {
+		if err := ValidateRule(rule); err != nil {
+			t.Errorf("ValidateRule(%q): %v", rule, err)
+		}
+	}
+
+	for _, rule := range []string{"Q", "$", "Ta"} {
+		if err := ValidateRule(rule); err == nil {
+			t.Errorf("ValidateRule(%q) accepted an invalid rule", rule)
+		}
+	}
+}
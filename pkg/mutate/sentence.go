@@ -0,0 +1,219 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Input modes accepted by structs.Config.Mode.
+const (
+	ModeLine     = "line"
+	ModeSentence = "sentence"
+)
+
+// validateMode checks an input mode.
+//
+// Args:
+// mode: string - Input mode; empty selects line mode.
+//
+// Returns:
+// error - Error if the mode is unknown.
+func validateMode(mode string) error {
+	switch mode {
+	case "", ModeLine, ModeSentence:
+		return nil
+	}
+
+	return fmt.Errorf("unknown input mode %q", mode)
+}
+
+// readSentences queues the sentences of a reader. Line breaks inside a
+// paragraph are read as spaces, so prose hard-wrapped at a fixed width
+// yields the same sentences as unwrapped prose. A sentence ends at '.', '!',
+// or '?' followed by whitespace, at a blank line, or at the end of the
+// input.
+//
+// Text without sentence ends is split at the maximum line size like a long
+// line, so memory stays bounded on input without punctuation. Every split
+// depends only on the text from the previous split onwards, so a resumed
+// run splits the rest of the input exactly as the original run would have.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// reader: *bufio.Reader - Decompressed input positioned at f.position.
+// name: string - Display name of the input used in error messages.
+//
+// Returns:
+// error - Error if reading fails for a reason other than EOF, or ctx is
+// cancelled.
+func (f *feeder) readSentences(ctx context.Context, reader *bufio.Reader, name string) error {
+	// text holds the input from f.position onwards byte for byte, with line
+	// breaks replaced by spaces. text[head:] is not yet queued, lineStart is
+	// where the current line begins, and scan is where the search for the
+	// next sentence end resumes. partial records that the current line
+	// arrived in several fragments, so that it is counted even when its last
+	// fragment is empty, as at the end of an input without a final newline.
+	var (
+		text                  []byte
+		head, lineStart, scan int
+		pieces                int64
+		partial               bool
+	)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if head > 0 {
+			text = text[:copy(text, text[head:])]
+			lineStart, scan, head = lineStart-head, scan-head, 0
+		}
+
+		fragment, readErr := reader.ReadSlice('\n')
+		f.counters.bytesRead.Add(int64(len(fragment)))
+		text = append(text, fragment...)
+
+		if errors.Is(readErr, bufio.ErrBufferFull) {
+			partial = true
+		} else if len(fragment) > 0 || partial {
+			f.counters.linesRead.Add(1)
+			partial = false
+		}
+
+		if !errors.Is(readErr, bufio.ErrBufferFull) && len(fragment) > 0 {
+			if len(bytes.TrimSpace(text[lineStart:])) == 0 {
+				// A blank line ends the paragraph and whatever sentence
+				// it left open.
+				if err := f.queueSentence(ctx, text[head:lineStart], len(text)-head, &pieces); err != nil {
+					return err
+				}
+
+				head, lineStart, scan = len(text), len(text), len(text)
+			} else {
+				joinLine(text)
+				lineStart = len(text)
+			}
+		}
+
+		for {
+			end := sentenceEnd(text, scan)
+
+			if end >= 0 && end-head <= f.maxLine {
+				if err := f.queueSentence(ctx, text[head:end], end-head, &pieces); err != nil {
+					return err
+				}
+
+				head, scan = end, end
+				continue
+			}
+
+			if len(text)-head <= f.maxLine {
+				break
+			}
+
+			cut := splitPoint(text[head:], f.maxLine)
+			if err := f.queue(ctx, text[head:head+cut], cut); err != nil {
+				return err
+			}
+
+			head += cut
+			scan = max(scan, head)
+			pieces++
+		}
+
+		// The last byte can only be judged once the next one is read. The
+		// text after a split is treated as the start of a line, as it is
+		// when a run resumes there.
+		scan = max(scan, len(text)-1, head)
+		lineStart = max(lineStart, head)
+
+		if errors.Is(readErr, io.EOF) && len(text) > head {
+			if err := f.queueSentence(ctx, text[head:], len(text)-head, &pieces); err != nil {
+				return err
+			}
+
+			head = len(text)
+		}
+
+		if f.batch.Len() > 0 && (readErr != nil || reader.Buffered() == 0) {
+			if err := f.flush(ctx); err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil && !errors.Is(readErr, bufio.ErrBufferFull) {
+			if errors.Is(readErr, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("error reading from %s: %w", name, readErr)
+		}
+	}
+}
+
+// queueSentence queues a complete sentence, or skips it if it holds only
+// whitespace. A sentence that had to be split is counted as a split line.
+//
+// Args:
+// ctx: context.Context - Context controlling cancellation.
+// sentence: []byte - Sentence text, or its last piece.
+// consumed: int - Input bytes the text covers.
+// pieces: *int64 - Pieces already queued for the sentence; reset to zero.
+//
+// Returns:
+// error - Context error if ctx is cancelled while sending.
+func (f *feeder) queueSentence(ctx context.Context, sentence []byte, consumed int, pieces *int64) error {
+	if *pieces > 0 {
+		f.counters.linesSplit.Add(1)
+		f.counters.splitPieces.Add(*pieces + 1)
+		*pieces = 0
+	}
+
+	if len(bytes.TrimSpace(sentence)) == 0 {
+		f.skip(consumed)
+		return nil
+	}
+
+	return f.queue(ctx, sentence, consumed)
+}
+
+// joinLine replaces the line break ending text with spaces, so that the
+// line continues into the next one. The text keeps its length, so offsets
+// into it remain input offsets.
+//
+// Args:
+// text: []byte - Text ending with a complete line.
+func joinLine(text []byte) {
+	if n := len(text); n > 0 && text[n-1] == '\n' {
+		text[n-1] = ' '
+
+		if n > 1 && text[n-2] == '\r' {
+			text[n-2] = ' '
+		}
+	}
+}
+
+// sentenceEnd finds the first sentence end in text at or after from: a '.',
+// '!', or '?' followed by whitespace.
+//
+// Args:
+// text: []byte - Text to search.
+// from: int - Index to start searching at.
+//
+// Returns:
+// int - Index just past the whitespace ending the sentence, or -1 if there
+// is none.
+func sentenceEnd(text []byte, from int) int {
+	for i := max(from, 0); i+1 < len(text); i++ {
+		if isSentenceEnd(text[i]) && isSplitSpace(text[i+1]) {
+			return i + 2
+		}
+	}
+
+	return -1
+}
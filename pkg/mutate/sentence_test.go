@@ -0,0 +1,123 @@
+package mutate
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+func TestReadSentences(t *testing.T) {
+	cases := []struct {
+		name    string
+		text    string
+		maxLine int
+		want    []string
+		lines   int64
+		split   int64
+		pieces  int64
+	}{
+		{
+			name:    "wrapped prose",
+			text:    "The quick brown\nfox jumps. Over\nthe dog\n",
+			maxLine: 1024,
+			want:    []string{"The quick brown fox jumps. ", "Over the dog "},
+			lines:   3,
+		},
+		{
+			name:    "terminators need whitespace",
+			text:    "Version 1.2 is out! Really? Yes.\n",
+			maxLine: 1024,
+			want:    []string{"Version 1.2 is out! ", "Really? ", "Yes. "},
+			lines:   1,
+		},
+		{
+			name:    "blank line ends a paragraph",
+			text:    "first paragraph\n\n  \nsecond paragraph",
+			maxLine: 1024,
+			want:    []string{"first paragraph ", "second paragraph"},
+			lines:   4,
+		},
+		{
+			name:    "CRLF line breaks",
+			text:    "one\r\ntwo.\r\n",
+			maxLine: 1024,
+			want:    []string{"one  two. "},
+			lines:   2,
+		},
+		{
+			name:    "no terminators are capped at the maximum",
+			text:    "aaa bbb ccc\nddd eee fff\n",
+			maxLine: 10,
+			want:    []string{"aaa bbb ", "ccc ddd ", "eee fff "},
+			lines:   2,
+			split:   1,
+			pieces:  3,
+		},
+	}
+
+	for _, tc := range cases {
+		for _, bufSize := range []int{16, 4096} {
+			got, end, counters := feedText(t, tc.text, tc.maxLine, bufSize, true)
+
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("%s (buffer %d): sentences %q, want %q", tc.name, bufSize, got, tc.want)
+				continue
+			}
+
+			for _, sentence := range got {
+				if len(sentence) > tc.maxLine {
+					t.Errorf("%s: sentence of %d bytes exceeds the maximum %d", tc.name, len(sentence), tc.maxLine)
+				}
+			}
+
+			// Only whitespace that produces no sentence may follow the
+			// position of the last batch.
+			if rest := tc.text[min(end.Offset, int64(len(tc.text))):]; strings.TrimSpace(rest) != "" {
+				t.Errorf("%s (buffer %d): end offset %d leaves %q unqueued", tc.name, bufSize, end.Offset, rest)
+			}
+
+			if counters.linesRead.Load() != tc.lines || counters.linesSplit.Load() != tc.split || counters.splitPieces.Load() != tc.pieces {
+				t.Errorf("%s (buffer %d): read %d, split %d, pieces %d; want %d, %d, %d", tc.name, bufSize,
+					counters.linesRead.Load(), counters.linesSplit.Load(), counters.splitPieces.Load(),
+					tc.lines, tc.split, tc.pieces)
+			}
+		}
+	}
+}
+
+func TestPipelineSentenceMode(t *testing.T) {
+	cfg := &structs.Config{
+		NGramMin:     2,
+		NGramMax:     2,
+		OutMinLength: 1,
+		OutMaxLength: 64,
+		CaseStyles:   []string{StyleLower},
+		Separators:   []string{" "},
+		Heuristics:   structs.Heuristics{Disabled: true},
+		Ordered:      true,
+		OrderWindow:  64,
+		Mode:         ModeSentence,
+	}
+
+	input := "The river ran\nsouth. A bird\nsang.\n\nNew paragraph\n"
+
+	var output strings.Builder
+	stats, err := NewPipeline(cfg).Run(context.Background(), strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Bigrams cross line breaks inside a sentence but never a sentence end
+	// or a paragraph break.
+	want := "the river\nriver ran\nran south\na bird\nbird sang\nnew paragraph\n"
+	if output.String() != want {
+		t.Fatalf("output %q, want %q", output.String(), want)
+	}
+
+	if stats.LinesRead != 5 || stats.LinesSplit != 0 {
+		t.Fatalf("stats read %d, split %d; want 5, 0", stats.LinesRead, stats.LinesSplit)
+	}
+}
@@ -0,0 +1,315 @@
+package mutate
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+)
+
+// spillEntryOverhead approximates the per-entry memory cost of a map entry
+// beyond the key bytes themselves.
+const spillEntryOverhead = 64
+
+// spillCounter aggregates per-candidate counts in memory and spills sorted
+// runs to temporary files once a memory cap is exceeded. Merge combines the
+// in-memory table and all runs into a single stream ordered by candidate.
+// It is safe for concurrent use.
+type spillCounter struct {
+	mu       sync.Mutex
+	counts   map[string]int64
+	memBytes int64
+	memLimit int64
+	tempDir  string
+	runs     []string
+}
+
+// newSpillCounter creates an empty spillCounter.
+//
+// Args:
+// memLimit: int64 - Approximate memory cap in bytes before spilling to disk.
+// tempDir: string - Directory for run files; the system default when empty.
+//
+// Returns:
+// *spillCounter - Empty counter.
+func newSpillCounter(memLimit int64, tempDir string) *spillCounter {
+	return &spillCounter{
+		counts:   make(map[string]int64),
+		memLimit: memLimit,
+		tempDir:  tempDir,
+	}
+}
+
+// Add increases the count of a candidate, spilling the in-memory table to a
+// sorted run file when the memory cap is reached.
+//
+// Args:
+// key: []byte - Candidate bytes.
+// n: int64 - Amount to add to the candidate's count.
+//
+// Returns:
+// error - Error if a run file cannot be written.
+func (c *spillCounter) Add(key []byte, n int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.counts[string(key)]; !exists {
+		c.memBytes += int64(len(key)) + spillEntryOverhead
+	}
+
+	c.counts[string(key)] += n
+
+	if c.memLimit > 0 && c.memBytes >= c.memLimit {
+		return c.spillLocked()
+	}
+
+	return nil
+}
+
+// spillLocked writes the in-memory table to a new sorted run file and
+// clears it. The caller must hold c.mu.
+//
+// Returns:
+// error - Error if the run file cannot be written.
+func (c *spillCounter) spillLocked() error {
+	if len(c.counts) == 0 {
+		return nil
+	}
+
+	file, err := os.CreateTemp(c.tempDir, "brainstorm-run-*")
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %w", err)
+	}
+
+	c.runs = append(c.runs, file.Name())
+
+	writer := bufio.NewWriterSize(file, 1<<20)
+	var scratch [binary.MaxVarintLen64]byte
+
+	for _, key := range slices.Sorted(maps.Keys(c.counts)) {
+		n := binary.PutUvarint(scratch[:], uint64(len(key)))
+		_, _ = writer.Write(scratch[:n])
+		_, _ = writer.WriteString(key)
+		n = binary.PutUvarint(scratch[:], uint64(c.counts[key]))
+		_, _ = writer.Write(scratch[:n])
+	}
+
+	if err := writer.Flush(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write spill file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close spill file: %w", err)
+	}
+
+	c.counts = make(map[string]int64)
+	c.memBytes = 0
+
+	return nil
+}
+
+// Merge calls fn once per distinct candidate, in ascending byte order, with
+// the candidate's total count. Merge must not be called concurrently with
+// Add.
+//
+// Args:
+// fn: func([]byte, int64) error - Callback; returning an error stops the merge.
+//
+// Returns:
+// error - Error from fn or from reading run files.
+func (c *spillCounter) Merge(fn func(key []byte, count int64) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.runs) == 0 {
+		for _, key := range slices.Sorted(maps.Keys(c.counts)) {
+			if err := fn([]byte(key), c.counts[key]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := c.spillLocked(); err != nil {
+		return err
+	}
+
+	readers := make([]*runReader, 0, len(c.runs))
+
+	defer func() {
+		for _, r := range readers {
+			_ = r.file.Close()
+		}
+	}()
+
+	var queue runHeap
+
+	for _, path := range c.runs {
+		r, err := openRunReader(path)
+		if err != nil {
+			return err
+		}
+
+		readers = append(readers, r)
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			queue = append(queue, r)
+		}
+	}
+
+	heap.Init(&queue)
+
+	var (
+		current []byte
+		total   int64
+		started bool
+	)
+
+	for queue.Len() > 0 {
+		r := queue[0]
+
+		if started && !bytes.Equal(r.key, current) {
+			if err := fn(current, total); err != nil {
+				return err
+			}
+			started = false
+		}
+
+		if !started {
+			current = append(current[:0], r.key...)
+			total = 0
+			started = true
+		}
+
+		total += r.count
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			heap.Fix(&queue, 0)
+		} else {
+			heap.Pop(&queue)
+		}
+	}
+
+	if started {
+		return fn(current, total)
+	}
+
+	return nil
+}
+
+// Close removes all run files.
+//
+// Returns:
+// error - First error encountered while removing files.
+func (c *spillCounter) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+
+	for _, path := range c.runs {
+		if err := os.Remove(path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	c.runs = nil
+	c.counts = make(map[string]int64)
+
+	return firstErr
+}
+
+// runReader reads (candidate, count) records from a sorted run file.
+type runReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	key    []byte
+	count  int64
+}
+
+// openRunReader opens a run file for sequential reading.
+//
+// Args:
+// path: string - Run file path.
+//
+// Returns:
+// *runReader - Reader positioned before the first record.
+// error - Error if the file cannot be opened.
+func openRunReader(path string) (*runReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spill file: %w", err)
+	}
+
+	return &runReader{file: file, reader: bufio.NewReaderSize(file, 1<<16)}, nil
+}
+
+// next advances to the following record.
+//
+// Returns:
+// bool - False once the run is exhausted.
+// error - Error if the run file is truncated or unreadable.
+func (r *runReader) next() (bool, error) {
+	keyLen, err := binary.ReadUvarint(r.reader)
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+
+	if uint64(cap(r.key)) < keyLen {
+		r.key = make([]byte, keyLen)
+	}
+
+	r.key = r.key[:keyLen]
+
+	if _, err := io.ReadFull(r.reader, r.key); err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+
+	count, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return false, fmt.Errorf("failed to read spill file: %w", err)
+	}
+
+	r.count = int64(count)
+
+	return true, nil
+}
+
+// runHeap is a min-heap of run readers ordered by their current key.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return bytes.Compare(h[i].key, h[j].key) < 0 }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
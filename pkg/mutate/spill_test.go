@@ -0,0 +1,87 @@
+package mutate
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestSpillCounterMergesRuns(t *testing.T) {
+	dir := t.TempDir()
+
+	// A tiny limit forces a spill every few distinct keys.
+	counter := newSpillCounter(3*spillEntryOverhead, dir)
+
+	want := make(map[string]int64)
+	for round := range 5 {
+		for i := range 20 {
+			key := fmt.Sprintf("key%02d", (i*7+round)%20)
+			if err := counter.Add([]byte(key), int64(round+1)); err != nil {
+				t.Fatalf("Add: %v", err)
+			}
+			want[key] += int64(round + 1)
+		}
+	}
+
+	if len(counter.runs) < 2 {
+		t.Fatalf("expected several runs, got %d", len(counter.runs))
+	}
+
+	var previous string
+	got := make(map[string]int64)
+
+	err := counter.Merge(func(key []byte, count int64) error {
+		if previous != "" && string(key) <= previous {
+			t.Fatalf("key %q after %q is out of order or repeated", key, previous)
+		}
+		previous = string(key)
+		got[string(key)] = count
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("merged %d keys, want %d", len(got), len(want))
+	}
+	for key, count := range want {
+		if got[key] != count {
+			t.Errorf("count of %q = %d, want %d", key, got[key], count)
+		}
+	}
+
+	runs := counter.runs
+	if err := counter.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, path := range runs {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("run file %s not removed", path)
+		}
+	}
+}
+
+func TestSpillCounterInMemory(t *testing.T) {
+	counter := newSpillCounter(0, t.TempDir())
+	defer counter.Close()
+
+	for _, key := range []string{"b", "a", "b", "c", "b"} {
+		if err := counter.Add([]byte(key), 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err := counter.Merge(func(key []byte, count int64) error {
+		got = append(got, fmt.Sprintf("%s=%d", key, count))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(got) != "[a=1 b=3 c=1]" {
+		t.Fatalf("merged %v", got)
+	}
+}
@@ -0,0 +1,62 @@
+package mutate
+
+import "unicode/utf8"
+
+// splitPoint returns where to cut the first piece off a line longer than
+// limit: just past the last sentence end followed by whitespace, otherwise
+// just past the last whitespace, otherwise at the last character boundary.
+// Only the first limit+1 bytes are inspected, so a long line is split the
+// same way however it was read.
+//
+// Args:
+// line: []byte - Line of more than limit bytes.
+// limit: int - Maximum length of a piece in bytes.
+//
+// Returns:
+// int - Length of the first piece, between 1 and limit.
+func splitPoint(line []byte, limit int) int {
+	window := line[:limit]
+
+	for i := len(window) - 1; i > 0; i-- {
+		if isSplitSpace(window[i]) && isSentenceEnd(window[i-1]) {
+			return i + 1
+		}
+	}
+
+	for i := len(window) - 1; i > 0; i-- {
+		if isSplitSpace(window[i]) {
+			return i + 1
+		}
+	}
+
+	for cut := limit; cut > 0; cut-- {
+		if utf8.RuneStart(line[cut]) {
+			return cut
+		}
+	}
+
+	return limit
+}
+
+// isSplitSpace reports whether a byte is ASCII whitespace a long line may be
+// split after.
+//
+// Args:
+// b: byte - Byte to check.
+//
+// Returns:
+// bool - True for spaces, tabs, and other ASCII whitespace.
+func isSplitSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\v' || b == '\f'
+}
+
+// isSentenceEnd reports whether a byte ends a sentence.
+//
+// Args:
+// b: byte - Byte to check.
+//
+// Returns:
+// bool - True for '.', '!', and '?'.
+func isSentenceEnd(b byte) bool {
+	return b == '.' || b == '!' || b == '?'
+}
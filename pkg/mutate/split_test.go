@@ -0,0 +1,137 @@
+package mutate
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+func TestSplitPoint(t *testing.T) {
+	cases := []struct {
+		name  string
+		line  string
+		limit int
+		want  int
+	}{
+		{"sentence end", "One two. Three four five", 12, 9},
+		{"prefers sentence over later space", "Go now! and then some more", 14, 8},
+		{"whitespace", "alpha beta gamma delta", 12, 11},
+		{"no whitespace", "abcdefghijklmnop", 8, 8},
+		{"rune boundary", "aaaaaaa\xc3\xa9bbbb", 8, 7},
+		{"space at limit ignored", "abcdefgh ijk", 8, 8},
+	}
+
+	for _, tc := range cases {
+		got := splitPoint([]byte(tc.line), tc.limit)
+		if got != tc.want {
+			t.Errorf("%s: splitPoint(%q, %d) = %d, want %d", tc.name, tc.line, tc.limit, got, tc.want)
+		}
+
+		if got < 1 || got > tc.limit {
+			t.Errorf("%s: split point %d outside 1..%d", tc.name, got, tc.limit)
+		}
+	}
+}
+
+// feedText runs a feeder over text and returns the queued lines, the input
+// position just past the last one, and the counters.
+func feedText(t *testing.T, text string, maxLine, bufSize int, sentences bool) ([]string, inputPosition, *runCounters) {
+	t.Helper()
+
+	batchCh := make(chan *lineBatch, 1024)
+	counters := &runCounters{}
+
+	f := &feeder{
+		batchCh:    batchCh,
+		batchLines: maxBatchLines,
+		counters:   counters,
+		bufferSize: bufSize,
+		maxLine:    maxLine,
+		sentences:  sentences,
+	}
+
+	if err := f.feedLines(context.Background(), strings.NewReader(text), "test", inputPosition{}); err != nil {
+		t.Fatalf("feedLines: %v", err)
+	}
+	close(batchCh)
+
+	var (
+		lines []string
+		end   inputPosition
+	)
+
+	for batch := range batchCh {
+		for line := range batch.Lines() {
+			lines = append(lines, string(line))
+		}
+		end = batch.End
+		releaseLineBatch(batch)
+	}
+
+	return lines, end, counters
+}
+
+func TestReadLinesSplitsLongLines(t *testing.T) {
+	line := "alpha beta. gamma delta epsilon zeta eta theta"
+	text := line + "\nshort line\n"
+
+	for _, bufSize := range []int{16, 4096} {
+		lines, end, counters := feedText(t, text, 20, bufSize, false)
+
+		want := []string{"alpha beta. ", "gamma delta epsilon ", "zeta eta theta", "short line"}
+		if !slices.Equal(lines, want) {
+			t.Fatalf("buffer %d: lines %q, want %q", bufSize, lines, want)
+		}
+
+		// The pieces of the long line add up to it exactly.
+		if strings.Join(lines[:3], "") != line {
+			t.Fatalf("buffer %d: pieces do not reassemble the line", bufSize)
+		}
+
+		if end.Offset != int64(len(text)) {
+			t.Fatalf("buffer %d: end offset %d, want %d", bufSize, end.Offset, len(text))
+		}
+
+		if counters.linesRead.Load() != 2 || counters.linesSplit.Load() != 1 || counters.splitPieces.Load() != 3 {
+			t.Fatalf("buffer %d: read %d, split %d, pieces %d; want 2, 1, 3", bufSize,
+				counters.linesRead.Load(), counters.linesSplit.Load(), counters.splitPieces.Load())
+		}
+	}
+}
+
+func TestPipelineSplitsLongLines(t *testing.T) {
+	cfg := &structs.Config{
+		NGramMin:     2,
+		NGramMax:     2,
+		OutMinLength: 1,
+		OutMaxLength: 64,
+		CaseStyles:   []string{StyleOriginal},
+		Separators:   []string{" "},
+		Heuristics:   structs.Heuristics{Disabled: true},
+		Ordered:      true,
+		OrderWindow:  64,
+		MaxLineSize:  20,
+		BufferSize:   16,
+	}
+
+	input := "alpha beta. gamma delta epsilon zeta eta theta\nshort line\n"
+
+	var output strings.Builder
+	stats, err := NewPipeline(cfg).Run(context.Background(), strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// No bigram spans a split, and none is lost or repeated.
+	want := "alpha beta\ngamma delta\ndelta epsilon\nzeta eta\neta theta\nshort line\n"
+	if output.String() != want {
+		t.Fatalf("output %q, want %q", output.String(), want)
+	}
+
+	if stats.LinesRead != 2 || stats.LinesSplit != 1 || stats.SplitPieces != 3 {
+		t.Fatalf("stats read %d, split %d, pieces %d; want 2, 1, 3", stats.LinesRead, stats.LinesSplit, stats.SplitPieces)
+	}
+}
@@ -0,0 +1,279 @@
+package mutate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// maxTrackedLength is the largest output length with its own histogram
+// bucket; longer candidates are counted in this bucket.
+const maxTrackedLength = 256
+
+// stageStats holds the stage counters of a single worker. Each worker owns
+// its own instance, so the counters need no synchronisation; they are merged
+// into the run statistics once the workers have finished.
+type stageStats struct {
+	stages     map[string]int64
+	rejected   map[string]int64
+	nGramSizes map[int]int64
+}
+
+// newStageStats creates empty stage counters.
+//
+// Returns:
+// *stageStats - Empty counters.
+func newStageStats() *stageStats {
+	return &stageStats{
+		stages:     make(map[string]int64),
+		rejected:   make(map[string]int64),
+		nGramSizes: make(map[int]int64),
+	}
+}
+
+// record counts the non-empty lines of a stage's output. For the n-gram
+// stage it also counts the words of each n-gram.
+//
+// Args:
+// name: string - Stage name.
+// data: []byte - Newline-delimited stage output.
+func (s *stageStats) record(name string, data []byte) {
+	for line := range bytes.SplitSeq(data, []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		s.stages[name]++
+
+		if name == stageNGrams {
+			s.nGramSizes[bytes.Count(line, []byte{' '})+1]++
+		}
+	}
+}
+
+// recordList counts the output of a stage held as a slice. For the n-gram
+// stage it also counts the words of each n-gram.
+//
+// Args:
+// name: string - Stage name.
+// lines: []string - Stage output.
+func (s *stageStats) recordList(name string, lines []string) {
+	s.stages[name] += int64(len(lines))
+
+	if name == stageNGrams {
+		for _, line := range lines {
+			s.nGramSizes[strings.Count(line, " ")+1]++
+		}
+	}
+}
+
+// mergeInto adds the counters to the run statistics.
+//
+// Args:
+// stats: *structs.Stats - Statistics to update.
+func (s *stageStats) mergeInto(stats *structs.Stats) {
+	if stats.StageOutputs == nil {
+		stats.StageOutputs = make(map[string]int64)
+		stats.Rejected = make(map[string]int64)
+		stats.NGramSizes = make(map[int]int64)
+	}
+
+	for name, count := range s.stages {
+		stats.StageOutputs[name] += count
+	}
+
+	for reason, count := range s.rejected {
+		stats.Rejected[reason] += count
+	}
+
+	for size, count := range s.nGramSizes {
+		stats.NGramSizes[size] += count
+	}
+}
+
+// recordOutput counts a written candidate and its length.
+//
+// Args:
+// candidate: []byte - Written candidate, without line ending.
+func (c *runCounters) recordOutput(candidate []byte) {
+	c.candidates.Add(1)
+	c.outputLengths[min(len(candidate), maxTrackedLength)].Add(1)
+}
+
+// outputLengthHistogram returns the non-empty output length buckets.
+//
+// Returns:
+// map[int]int64 - Candidates written, by length in bytes.
+func (c *runCounters) outputLengthHistogram() map[int]int64 {
+	histogram := make(map[int]int64)
+
+	for length := range c.outputLengths {
+		if count := c.outputLengths[length].Load(); count > 0 {
+			histogram[length] = count
+		}
+	}
+
+	return histogram
+}
+
+// perSecond returns a rate for the run duration.
+//
+// Args:
+// stats: structs.Stats - Statistics for the run.
+// count: int64 - Quantity processed during the run.
+//
+// Returns:
+// float64 - Quantity per second, or 0 for an empty duration.
+func perSecond(stats structs.Stats, count int64) float64 {
+	seconds := stats.Duration.Seconds()
+	if seconds <= 0 {
+		return 0
+	}
+
+	return float64(count) / seconds
+}
+
+// formatHistogram formats histogram buckets as "key:count" pairs in key
+// order.
+//
+// Args:
+// histogram: map[int]int64 - Histogram to format.
+//
+// Returns:
+// string - Formatted buckets, or "none".
+func formatHistogram(histogram map[int]int64) string {
+	if len(histogram) == 0 {
+		return "none"
+	}
+
+	parts := make([]string, 0, len(histogram))
+	for _, key := range slices.Sorted(maps.Keys(histogram)) {
+		label := fmt.Sprint(key)
+		if key == maxTrackedLength {
+			label += "+"
+		}
+
+		parts = append(parts, fmt.Sprintf("%s:%d", label, histogram[key]))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// reportStats prints a summary of the run statistics to stderr.
+//
+// Args:
+// stats: structs.Stats - Statistics for the run.
+func reportStats(stats structs.Stats) {
+	fmt.Fprintf(os.Stderr, "[*] Read %d lines (%d bytes) from %d inputs with %d workers in %s: %.0f lines/s, %.0f bytes/s.\n",
+		stats.LinesRead, stats.BytesRead, stats.Inputs, stats.Workers, stats.Duration.Round(1e6),
+		perSecond(stats, stats.LinesRead), perSecond(stats, stats.BytesRead))
+
+	var stages []string
+	for _, name := range stageOrder {
+		if count, exists := stats.StageOutputs[name]; exists {
+			stages = append(stages, fmt.Sprintf("%s:%d", name, count))
+		}
+	}
+
+	if len(stages) > 0 {
+		fmt.Fprintf(os.Stderr, "[*] Stage outputs: %s.\n", strings.Join(stages, " "))
+	}
+
+	if len(stats.Rejected) > 0 {
+		reasons := slices.SortedFunc(maps.Keys(stats.Rejected), func(a, b string) int {
+			if stats.Rejected[a] != stats.Rejected[b] {
+				return int(stats.Rejected[b] - stats.Rejected[a])
+			}
+			return strings.Compare(a, b)
+		})
+
+		parts := make([]string, 0, len(reasons))
+		for _, reason := range reasons {
+			parts = append(parts, fmt.Sprintf("%s:%d", reason, stats.Rejected[reason]))
+		}
+
+		fmt.Fprintf(os.Stderr, "[*] Rejected: %s.\n", strings.Join(parts, " "))
+	}
+
+	if stats.LinesSplit > 0 {
+		fmt.Fprintf(os.Stderr, "[*] Split %d long lines into %d pieces.\n", stats.LinesSplit, stats.SplitPieces)
+	}
+
+	fmt.Fprintf(os.Stderr, "[*] N-gram sizes: %s.\n", formatHistogram(stats.NGramSizes))
+	fmt.Fprintf(os.Stderr, "[*] Output lengths: %s.\n", formatHistogram(stats.OutputLengths))
+
+	for _, excluded := range stats.Excluded {
+		fmt.Fprintf(os.Stderr, "[*] Exclude list %s removed %d candidates.\n", excluded.List, excluded.Removed)
+	}
+
+	fmt.Fprintf(os.Stderr, "[*] Wrote %d candidates (%d duplicates removed): %.0f candidates/s.\n",
+		stats.Candidates, stats.Duplicates, perSecond(stats, stats.Candidates))
+}
+
+// statsReport is the JSON form of structs.Stats.
+type statsReport struct {
+	Workers             int                   `json:"workers"`
+	Inputs              int                   `json:"inputs"`
+	LinesRead           int64                 `json:"lines_read"`
+	BytesRead           int64                 `json:"bytes_read"`
+	LinesSplit          int64                 `json:"lines_split"`
+	SplitPieces         int64                 `json:"split_pieces"`
+	Candidates          int64                 `json:"candidates"`
+	Duplicates          int64                 `json:"duplicates"`
+	DurationSeconds     float64               `json:"duration_seconds"`
+	LinesPerSecond      float64               `json:"lines_per_second"`
+	BytesPerSecond      float64               `json:"bytes_per_second"`
+	CandidatesPerSecond float64               `json:"candidates_per_second"`
+	StageOutputs        map[string]int64      `json:"stage_outputs"`
+	Rejected            map[string]int64      `json:"rejected"`
+	NGramSizes          map[int]int64         `json:"ngram_sizes"`
+	OutputLengths       map[int]int64         `json:"output_lengths"`
+	Excluded            []structs.ExcludeStat `json:"excluded,omitempty"`
+}
+
+// writeStatsJSON writes the run statistics to a file as JSON.
+//
+// Args:
+// stats: structs.Stats - Statistics for the run.
+// path: string - Output file path.
+//
+// Returns:
+// error - Error if the file cannot be written.
+func writeStatsJSON(stats structs.Stats, path string) error {
+	report := statsReport{
+		Workers:             stats.Workers,
+		Inputs:              stats.Inputs,
+		LinesRead:           stats.LinesRead,
+		BytesRead:           stats.BytesRead,
+		LinesSplit:          stats.LinesSplit,
+		SplitPieces:         stats.SplitPieces,
+		Candidates:          stats.Candidates,
+		Duplicates:          stats.Duplicates,
+		DurationSeconds:     stats.Duration.Seconds(),
+		LinesPerSecond:      perSecond(stats, stats.LinesRead),
+		BytesPerSecond:      perSecond(stats, stats.BytesRead),
+		CandidatesPerSecond: perSecond(stats, stats.Candidates),
+		StageOutputs:        stats.StageOutputs,
+		Rejected:            stats.Rejected,
+		NGramSizes:          stats.NGramSizes,
+		OutputLengths:       stats.OutputLengths,
+		Excluded:            stats.Excluded,
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode statistics: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write statistics file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package mutate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Reason codes recorded for lines and candidates dropped by the heuristics.
+const (
+	RejectNoLetters           = "no_letters"
+	RejectTooShort            = "too_short"
+	RejectAlphaNumericRun     = "alphanumeric_run"
+	RejectNonLetterDensity    = "non_letter_density"
+	RejectUncommonBigrams     = "uncommon_bigrams"
+	RejectVowelFreeWindows    = "vowel_free_windows"
+	RejectNoSyllables         = "no_syllables"
+	RejectFewSyllables        = "few_syllables"
+	RejectVowelRatioLow       = "vowel_ratio_low"
+	RejectVowelRatioHigh      = "vowel_ratio_high"
+	RejectConsonantRun        = "consonant_run"
+	RejectVowelRun            = "vowel_run"
+	RejectNoWordWindow        = "no_word_window"
+	RejectUnbalancedDelimiter = "unbalanced_delimiter"
+	RejectQuoteNoise          = "quote_noise"
+	RejectModelScoreLow       = "model_score_low"
+	RejectLengthOutOfRange    = "length_out_of_range"
+)
+
+// Stage names reported by transformLine.
+const (
+	stageTrim          = "trim"
+	stageHeuristics    = "heuristics"
+	stageNGrams        = "ngrams"
+	stageStyles        = "styles"
+	stagePostFilters   = "post-filters"
+	stageSubstitutions = "substitutions"
+	stageAffixes       = "affixes"
+	stageLength        = "length"
+)
+
+// stageOrder lists the stages in pipeline order.
+var stageOrder = []string{
+	stageTrim, stageHeuristics, stageNGrams, stageStyles,
+	stagePostFilters, stageSubstitutions, stageAffixes, stageLength,
+}
+
+// rejection describes why a line or candidate was dropped. Value and limit
+// hold the measurement that failed and the threshold it crossed, when the
+// reason has one; both are zero otherwise.
+type rejection struct {
+	reason string
+	value  float64
+	limit  float64
+}
+
+// String formats the rejection for humans, for example
+// "vowel_ratio_low (0.18, limit 0.25)".
+//
+// Returns:
+// string - Formatted rejection.
+func (r rejection) String() string {
+	if r.value == 0 && r.limit == 0 {
+		return r.reason
+	}
+
+	return fmt.Sprintf("%s (%s, limit %s)", r.reason,
+		strconv.FormatFloat(r.value, 'g', 3, 64), strconv.FormatFloat(r.limit, 'g', 3, 64))
+}
+
+// transformTrace receives rejections, optionally notes on candidates that
+// were rewritten rather than dropped, and the output of each stage of
+// transformLine. A nil trace ignores everything, so the hot path pays only
+// a nil check. A trace with counts must not be shared between goroutines.
+type transformTrace struct {
+	reject  func(text string, why rejection)
+	replace func(text string, replacement string, reason string)
+	output  func(name string, output []string)
+	counts  *stageStats
+}
+
+// rejected reports a dropped line or candidate.
+//
+// Args:
+// text: string - Dropped line or candidate.
+// why: rejection - Reason it was dropped.
+func (t *transformTrace) rejected(text string, why rejection) {
+	if t == nil {
+		return
+	}
+
+	if t.counts != nil {
+		t.counts.rejected[why.reason]++
+	}
+
+	if t.reject != nil {
+		t.reject(text, why)
+	}
+}
+
+// replaced reports a candidate that a stage rewrote instead of keeping.
+// Unlike a rejection, it is not counted or written to the rejects file,
+// since the rewritten candidate is still produced.
+//
+// Args:
+// text: string - Original candidate.
+// replacement: string - Candidate produced in its place.
+// reason: string - Reason code for the rewrite.
+func (t *transformTrace) replaced(text string, replacement string, reason string) {
+	if t == nil || t.replace == nil {
+		return
+	}
+
+	t.replace(text, replacement, reason)
+}
+
+// stage reports the newline-delimited output of a stage.
+//
+// Args:
+// name: string - Stage name.
+// data: []byte - Newline-delimited stage output.
+func (t *transformTrace) stage(name string, data []byte) {
+	if t == nil {
+		return
+	}
+
+	if t.counts != nil {
+		t.counts.record(name, data)
+	}
+
+	if t.output == nil {
+		return
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	t.output(name, lines)
+}
+
+// stageList reports the output of a stage held as a slice.
+//
+// Args:
+// name: string - Stage name.
+// lines: []string - Stage output.
+func (t *transformTrace) stageList(name string, lines []string) {
+	if t == nil {
+		return
+	}
+
+	if t.counts != nil {
+		t.counts.recordList(name, lines)
+	}
+
+	if t.output != nil {
+		t.output(name, lines)
+	}
+}
+
+// rejectsWriter writes rejected lines and candidates to a sidecar file as
+// "reason<TAB>text" lines. It is safe for concurrent use.
+type rejectsWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	err    error
+}
+
+// newRejectsWriter creates the rejects file named by the configuration.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// *rejectsWriter - Writer, or nil when no rejects file is configured.
+// error - Error if the file cannot be created.
+func newRejectsWriter(cfg *structs.Config) (*rejectsWriter, error) {
+	if cfg.RejectsOut == "" {
+		return nil, nil
+	}
+
+	file, err := os.Create(cfg.RejectsOut)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rejects file: %w", err)
+	}
+
+	return &rejectsWriter{file: file, writer: bufio.NewWriterSize(file, 1<<20)}, nil
+}
+
+// Record writes one rejected line or candidate. Write errors are kept and
+// returned by Close.
+//
+// Args:
+// text: string - Dropped line or candidate.
+// why: rejection - Reason it was dropped.
+func (w *rejectsWriter) Record(text string, why rejection) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return
+	}
+
+	_, _ = w.writer.WriteString(why.reason)
+	_ = w.writer.WriteByte('\t')
+	_, _ = w.writer.WriteString(text)
+	w.err = w.writer.WriteByte('\n')
+}
+
+// Close flushes and closes the rejects file.
+//
+// Returns:
+// error - First error encountered while writing or closing.
+func (w *rejectsWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err == nil {
+		w.err = w.writer.Flush()
+	}
+
+	if err := w.file.Close(); w.err == nil {
+		w.err = err
+	}
+
+	if w.err != nil {
+		return fmt.Errorf("failed to write rejects file: %w", w.err)
+	}
+
+	return nil
+}
+
+// Explain runs a single input line through the transformation and returns
+// a human-readable trace of every stage: its output, and each line or
+// candidate it dropped with the reason and the measurement that failed.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+// text: string - Input line to explain.
+//
+// Returns:
+// []string - Trace lines, ending with the final candidates.
+func Explain(cfg *structs.Config, text string) []string {
+	trace := []string{"input: " + strconv.Quote(text)}
+
+	var (
+		pending []string
+		dropped int
+	)
+
+	t := &transformTrace{
+		reject: func(text string, why rejection) {
+			pending = append(pending, fmt.Sprintf("  - %s: %s", strconv.Quote(text), why))
+			dropped++
+		},
+		replace: func(text string, replacement string, reason string) {
+			pending = append(pending, fmt.Sprintf("  ~ %s: %s, replaced by %s", strconv.Quote(text), reason, strconv.Quote(replacement)))
+		},
+		output: func(name string, output []string) {
+			trace = append(trace, fmt.Sprintf("%s: %d kept, %d dropped", name, len(output), dropped))
+
+			for _, line := range output {
+				trace = append(trace, "  + "+strconv.Quote(line))
+			}
+
+			trace = append(trace, pending...)
+			pending, dropped = pending[:0], 0
+		},
+	}
+
+	output := transformLine(cfg, []byte(text), t)
+
+	if len(output) == 0 {
+		return append(trace, "result: no candidates")
+	}
+
+	return append(trace, fmt.Sprintf("result: %d candidates", strings.Count(string(output), "\n")+1))
+}
@@ -0,0 +1,62 @@
+package mutate
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQuoteNoiseReplacementIsNotRejected(t *testing.T) {
+	var rejected, replaced []string
+
+	trace := &transformTrace{
+		reject: func(text string, why rejection) {
+			rejected = append(rejected, why.reason+" "+text)
+		},
+		replace: func(text string, replacement string, reason string) {
+			replaced = append(replaced, reason+" "+text+" "+replacement)
+		},
+		counts: newStageStats(),
+	}
+
+	got := applyPostFilters([]string{"Don't Stop", "Rock`n Roll"}, trace)
+
+	if want := []string{"Dont Stop"}; !slices.Equal(got, want) {
+		t.Fatalf("applyPostFilters = %q, want %q", got, want)
+	}
+
+	if want := []string{"quote_noise Don't Stop Dont Stop"}; !slices.Equal(replaced, want) {
+		t.Errorf("replaced %q, want %q", replaced, want)
+	}
+
+	// Only the candidate without an apostrophe-free variant is dropped.
+	if want := []string{"quote_noise Rock`n Roll"}; !slices.Equal(rejected, want) {
+		t.Errorf("rejected %q, want %q", rejected, want)
+	}
+
+	if count := trace.counts.rejected[RejectQuoteNoise]; count != 1 {
+		t.Errorf("counted %d quote_noise rejections, want 1", count)
+	}
+}
+
+func TestExplainQuoteNoiseNote(t *testing.T) {
+	cfg := testConfig()
+	cfg.NGramMax = 2
+
+	lines := Explain(cfg, "don't panic")
+
+	var postFilters string
+	for _, line := range lines {
+		if strings.HasPrefix(line, stagePostFilters+":") {
+			postFilters = line
+		}
+	}
+
+	if postFilters == "" || !strings.HasSuffix(postFilters, " 0 dropped") {
+		t.Fatalf("post-filter summary %q, want no dropped candidates in %q", postFilters, lines)
+	}
+
+	if !slices.Contains(lines, `  ~ "Don'tPanic": quote_noise, replaced by "DontPanic"`) {
+		t.Errorf("missing replacement note in %q", lines)
+	}
+}
@@ -0,0 +1,161 @@
+package mutate
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/hashcracky/brainstorm/pkg/structs"
+)
+
+// Pipeline sizing defaults used when the configuration leaves them unset.
+const (
+	DefaultQueueSize   = 1024
+	DefaultBufferSize  = 1 << 20
+	DefaultMaxLineSize = 64 << 10
+)
+
+// cgroup files holding the CPU quota of the current container, relative to
+// cgroupRoot. Version 2 exposes "quota period" in a single file, version 1
+// two separate files.
+const (
+	cgroupRoot        = "/sys/fs/cgroup"
+	cgroupV2CPUMax    = "cpu.max"
+	cgroupV1CPUQuota  = "cpu/cpu.cfs_quota_us"
+	cgroupV1CPUPeriod = "cpu/cpu.cfs_period_us"
+)
+
+// AutoWorkerCount returns the number of workers to run when none is
+// configured: the number of CPUs, capped by the cgroup CPU quota when the
+// process runs in a container with one.
+//
+// Returns:
+// int - Worker count, at least 1.
+func AutoWorkerCount() int {
+	workers := runtime.NumCPU()
+
+	if quota, ok := cgroupCPUQuota(cgroupRoot); ok {
+		workers = min(workers, int(math.Ceil(quota)))
+	}
+
+	return max(workers, 1)
+}
+
+// cgroupCPUQuota reads the CPU quota of the current cgroup, in CPUs.
+//
+// Args:
+// root: string - Mount point of the cgroup file system.
+//
+// Returns:
+// float64 - Number of CPUs the cgroup may use.
+// bool - False if there is no quota or it cannot be read.
+func cgroupCPUQuota(root string) (float64, bool) {
+	if data, err := os.ReadFile(filepath.Join(root, cgroupV2CPUMax)); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, false
+		}
+
+		return cpuQuotaRatio(fields[0], fields[1])
+	}
+
+	quota, err := os.ReadFile(filepath.Join(root, cgroupV1CPUQuota))
+	if err != nil {
+		return 0, false
+	}
+
+	period, err := os.ReadFile(filepath.Join(root, cgroupV1CPUPeriod))
+	if err != nil {
+		return 0, false
+	}
+
+	return cpuQuotaRatio(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuQuotaRatio divides a cgroup CPU quota by its period.
+//
+// Args:
+// quota: string - Microseconds of CPU time per period; negative for none.
+// period: string - Period length in microseconds.
+//
+// Returns:
+// float64 - Number of CPUs the quota allows.
+// bool - False if there is no quota or the values are malformed.
+func cpuQuotaRatio(quota string, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+
+	return q / p, true
+}
+
+// workerCount returns the configured number of workers, or the automatic
+// count when none is set.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// int - Worker count.
+func workerCount(cfg *structs.Config) int {
+	if cfg.Threads > 0 {
+		return cfg.Threads
+	}
+
+	return AutoWorkerCount()
+}
+
+// queueSize returns the configured worker queue length, or the default.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// int - Number of lines the worker queue holds, before rounding to whole batches.
+func queueSize(cfg *structs.Config) int {
+	if cfg.QueueSize > 0 {
+		return cfg.QueueSize
+	}
+
+	return DefaultQueueSize
+}
+
+// bufferSize returns the configured input and output buffer size, or the
+// default.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// int - Buffer size in bytes.
+func bufferSize(cfg *structs.Config) int {
+	if cfg.BufferSize > 0 {
+		return cfg.BufferSize
+	}
+
+	return DefaultBufferSize
+}
+
+// maxLineSize returns the configured maximum line size, or the default.
+//
+// Args:
+// cfg: *structs.Config - Application configuration.
+//
+// Returns:
+// int - Maximum line length in bytes before a line is split.
+func maxLineSize(cfg *structs.Config) int {
+	if cfg.MaxLineSize > 0 {
+		return cfg.MaxLineSize
+	}
+
+	return DefaultMaxLineSize
+}
@@ -0,0 +1,56 @@
+package mutate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCgroupCPUQuota(t *testing.T) {
+	cases := []struct {
+		name   string
+		files  map[string]string
+		want   float64
+		wantOK bool
+	}{
+		{"v2 quota", map[string]string{cgroupV2CPUMax: "150000 100000\n"}, 1.5, true},
+		{"v2 unlimited", map[string]string{cgroupV2CPUMax: "max 100000\n"}, 0, false},
+		{"v2 malformed", map[string]string{cgroupV2CPUMax: "150000\n"}, 0, false},
+		{"v2 zero period", map[string]string{cgroupV2CPUMax: "150000 0\n"}, 0, false},
+		{"v1 quota", map[string]string{cgroupV1CPUQuota: "200000\n", cgroupV1CPUPeriod: "100000\n"}, 2, true},
+		{"v1 unlimited", map[string]string{cgroupV1CPUQuota: "-1\n", cgroupV1CPUPeriod: "100000\n"}, 0, false},
+		{"v1 missing period", map[string]string{cgroupV1CPUQuota: "200000\n"}, 0, false},
+		{"v1 malformed", map[string]string{cgroupV1CPUQuota: "lots\n", cgroupV1CPUPeriod: "100000\n"}, 0, false},
+		{
+			"v2 takes precedence",
+			map[string]string{
+				cgroupV2CPUMax:    "50000 100000\n",
+				cgroupV1CPUQuota:  "400000\n",
+				cgroupV1CPUPeriod: "100000\n",
+			},
+			0.5, true,
+		},
+		{"no cgroup files", nil, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+
+			for name, content := range tc.files {
+				path := filepath.Join(root, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, ok := cgroupCPUQuota(root)
+			if got != tc.want || ok != tc.wantOK {
+				t.Fatalf("cgroupCPUQuota = %v, %v, want %v, %v", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
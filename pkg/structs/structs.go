@@ -1,6 +1,8 @@
 // Package structs contains the model used by the application
 package structs
 
+import "time"
+
 // Config holds all configuration options for the brainstorm application.
 //
 // Args:
@@ -9,6 +11,49 @@ package structs
 // outMinLength: int - Minimum output string length.
 // outMaxLength: int - Maximum output string length.
 // includeNonLatin: bool - When true, relax Latin vowel heuristics to allow multi-byte non-Latin letter sequences.
+// inputPaths: []string - Files, directories, or glob patterns to read; stdin is used when empty.
+// recursive: bool - When true, descend into subdirectories of directory inputs.
+// includeGlobs: []string - File name patterns a directory entry must match to be read.
+// excludeGlobs: []string - File name patterns that skip a directory entry.
+// mode: string - Unit of input processed at a time: "line" or "sentence"; lines when empty.
+// dedup: string - Deduplication strategy: "" (off), "exact", "bloom", or "disk".
+// dedupFalsePositive: float64 - Target false-positive rate of the bloom strategy.
+// dedupCapacity: int - Expected number of distinct candidates for the bloom strategy.
+// sortMemoryMB: int - Memory cap in MiB before disk-backed stages spill to temporary files.
+// tempDir: string - Directory for temporary spill files; the system default when empty.
+// count: bool - When true, tally candidates and write them by descending frequency.
+// countFormat: string - Count output format: "tsv" (count<TAB>candidate) or "plain" (candidate only).
+// countTop: int - Maximum number of ranked candidates to write; 0 writes all.
+// countMin: int64 - Minimum number of occurrences for a candidate to be written.
+// ordered: bool - When true, write results in input order.
+// orderWindow: int - Maximum number of input lines in flight while reordering.
+// caseStyles: []string - Case styles applied to each n-gram; title when empty.
+// separators: []string - Strings joining the words of each n-gram; no separator when empty.
+// leetMode: string - Character substitution mode: "" (off), "all", "single", or "first".
+// leetTable: map[rune][]string - Substitution table keyed by lowercase letter; the built-in table when nil.
+// leetPositions: int - Number of leading substitutable positions used by the "first" mode.
+// leetMaxVariants: int - Maximum substitution variants per candidate; 0 is unlimited.
+// prefixes: []string - Affixes prepended to each candidate.
+// suffixes: []string - Affixes appended to each candidate.
+// rulesOut: string - When set, write base candidates only and a hashcat rule file to this path.
+// hashFile: string - When set, only write candidates whose hash appears in this file, as hash:plaintext.
+// hashAlgo: string - Hash algorithm of the target hashes: md5, sha1, sha256, sha512, or ntlm.
+// potFile: string - Existing potfile whose hashes and plaintexts are skipped.
+// heuristics: Heuristics - Word-likeness thresholds; the default preset when zero.
+// model: *CharModel - Optional character model; lines with a word scoring below minModelScore are rejected.
+// minModelScore: float64 - Minimum mean log10 probability per character of each word under the model.
+// language: string - Language profile for the word heuristics, or "auto" to pick one per line; English when empty.
+// rejectsOut: string - When set, write every line and candidate dropped by the heuristics to this file with a reason code.
+// statsJSON: string - When set, write the run statistics to this file as JSON.
+// progress: bool - When true, periodically print progress to stderr.
+// checkpoint: string - When set, periodically record the committed input position and output size in this file.
+// resume: bool - When true, continue from the position recorded in the checkpoint file.
+// threads: int - Number of worker goroutines; sized from the CPUs and cgroup CPU quota when 0.
+// queueSize: int - Number of input lines the worker queue holds, rounded up to whole batches and at least one batch per worker; 1024 when 0.
+// bufferSize: int - Size in bytes of the input and output buffers; 1 MiB when 0.
+// maxLineSize: int - Lines, or sentences in sentence mode, longer than this many bytes are split on sentence or whitespace boundaries; 64 KiB when 0.
+// explain: string - When set, print the decision trace for this single input instead of processing input.
+// excludeLists: []string - Wordlists whose entries are removed from the output; "sorted:" and "bloom:" prefixes select the backend.
 //
 // Returns:
 // Config - Configuration object for the application.
@@ -18,4 +63,166 @@ type Config struct {
 	OutMinLength    int
 	OutMaxLength    int
 	IncludeNonLatin bool
+	InputPaths      []string
+	Recursive       bool
+	IncludeGlobs    []string
+	ExcludeGlobs    []string
+	Mode            string
+
+	Dedup              string
+	DedupFalsePositive float64
+	DedupCapacity      int
+	SortMemoryMB       int
+	TempDir            string
+
+	Count       bool
+	CountFormat string
+	CountTop    int
+	CountMin    int64
+
+	Ordered     bool
+	OrderWindow int
+
+	CaseStyles []string
+	Separators []string
+
+	LeetMode        string
+	LeetTable       map[rune][]string
+	LeetPositions   int
+	LeetMaxVariants int
+
+	Prefixes []string
+	Suffixes []string
+
+	RulesOut string
+
+	HashFile string
+	HashAlgo string
+	PotFile  string
+
+	ExcludeLists []string
+
+	Heuristics Heuristics
+	Language   string
+
+	Model         *CharModel
+	MinModelScore float64
+
+	RejectsOut string
+	StatsJSON  string
+	Explain    string
+
+	Progress bool
+
+	Checkpoint string
+	Resume     bool
+
+	Threads    int
+	QueueSize  int
+	BufferSize int
+
+	MaxLineSize int
+}
+
+// Heuristics holds the thresholds used to decide whether an input line
+// looks like natural-language words. A line is rejected when a measurement
+// falls outside its threshold.
+//
+// Args:
+// disabled: bool - When true, skip the word-likeness checks and keep every line containing a letter.
+// minVowelRatio: float64 - Minimum ratio of vowels to letters.
+// maxVowelRatio: float64 - Maximum ratio of vowels to letters.
+// maxConsonantRun: int - Maximum number of consecutive consonants.
+// maxVowelRun: int - Maximum number of consecutive vowels.
+// longWordLength: int - Length in bytes from which minLongWordSyllables applies.
+// minLongWordSyllables: int - Minimum number of syllable-like segments in long strings.
+// maxUncommonBigramRatio: float64 - Maximum ratio of uncommon letter pairs to letter pairs.
+// maxVowelFreeWindowRatio: float64 - Maximum ratio of five-character windows without a vowel to letter pairs.
+// maxNonLetterRatio: float64 - Maximum ratio of digits and symbols to letters, digits, and symbols.
+// maxMixedRun: int - Maximum length of a run mixing letters and digits.
+//
+// Returns:
+// Heuristics - Word-likeness thresholds.
+type Heuristics struct {
+	Disabled                bool
+	MinVowelRatio           float64
+	MaxVowelRatio           float64
+	MaxConsonantRun         int
+	MaxVowelRun             int
+	LongWordLength          int
+	MinLongWordSyllables    int
+	MaxUncommonBigramRatio  float64
+	MaxVowelFreeWindowRatio float64
+	MaxNonLetterRatio       float64
+	MaxMixedRun             int
+}
+
+// Stats holds the counters collected during a single pipeline run.
+//
+// Args:
+// workers: int - Number of worker goroutines used.
+// inputs: int - Number of input streams read.
+// linesRead: int64 - Number of input lines read.
+// bytesRead: int64 - Number of (decompressed) input bytes read.
+// candidates: int64 - Number of candidates written to the output.
+// duplicates: int64 - Number of candidates dropped by deduplication.
+// linesSplit: int64 - Number of input lines, or sentences in sentence mode, split because they exceeded the maximum line size.
+// splitPieces: int64 - Number of pieces the split lines were cut into.
+// excluded: []ExcludeStat - Number of candidates removed by each exclude list.
+// stageOutputs: map[string]int64 - Number of lines or candidates each transformation stage produced.
+// rejected: map[string]int64 - Number of lines and candidates dropped, by reason code.
+// nGramSizes: map[int]int64 - Number of n-grams generated, by word count.
+// outputLengths: map[int]int64 - Number of candidates written, by length in bytes.
+// duration: time.Duration - Wall-clock duration of the run.
+//
+// Returns:
+// Stats - Statistics for the run.
+type Stats struct {
+	Workers    int
+	Inputs     int
+	LinesRead  int64
+	BytesRead  int64
+	Candidates int64
+	Duplicates int64
+	Excluded   []ExcludeStat
+	Duration   time.Duration
+
+	LinesSplit  int64
+	SplitPieces int64
+
+	StageOutputs  map[string]int64
+	Rejected      map[string]int64
+	NGramSizes    map[int]int64
+	OutputLengths map[int]int64
+}
+
+// ExcludeStat holds the number of candidates removed by one exclude list.
+//
+// Args:
+// list: string - Exclude list as given in the configuration.
+// removed: int64 - Number of candidates removed by the list.
+//
+// Returns:
+// ExcludeStat - Removal count for the list.
+type ExcludeStat struct {
+	List    string
+	Removed int64
+}
+
+// CharModel is a character n-gram (Markov) language model trained on
+// reference text, used to score how word-like a token is.
+//
+// Args:
+// order: int - Number of characters per n-gram, including the predicted character.
+// alphabet: int - Number of distinct characters seen in training, used for smoothing.
+// counts: map[string]uint32 - Occurrences of each n-gram.
+// contexts: map[string]uint64 - Occurrences of each (order-1)-character context, derived from counts.
+//
+// Returns:
+// CharModel - Trained model.
+type CharModel struct {
+	Order    int
+	Alphabet int
+	Counts   map[string]uint32
+	Contexts map[string]uint64
 }